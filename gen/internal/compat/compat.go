@@ -9,11 +9,14 @@ import "strings"
 type GoCompat struct {
 	Min string
 	Max *string //soppo:nilable
+	Bad []string
 }
 
 // Minimum required Go version (inclusive)
 // Maximum supported Go version (inclusive), nil means no upper bound
-// GoCompatFor returns the Go version compatibility for a given sop version.
+// Go versions in range that are known not to work
+// GoCompatFor returns the Go version compatibility for a given sop version,
+// as the compat manifest describes it.
 // Returns nil if the version is unknown or doesn't have specific requirements.
 //
 // ```sop
@@ -25,22 +28,16 @@ type GoCompat struct {
 // ```
 //soppo:nilable : 0
 func GoCompatFor(sopVersion string) *GoCompat {
-	version := strings.TrimPrefix(sopVersion, "v")
-
-	major, minor, _, _err0 := parseVersion(version)
-	if _err0 != nil {
+	entry := current().For(sopVersion)
+	if entry == nil {
 		return nil
 	}
 
-	// Add new Go requirements here (check newest first)
-	// if major > 0 || (major == 0 && minor >= 6) {
-	//     return &GoCompat{Min: "1.25", Max: nil}
-	// }
-	if major > 0 || (major == 0 && minor >= 0) {
-		return (&GoCompat{Min: "1.21", Max: nil})
+	compat := (&GoCompat{Min: entry.GoMin, Bad: entry.GoBad})
+	if entry.GoMax != "" {
+		compat.Max = (&entry.GoMax)
 	}
-
-	return (&GoCompat{Min: "1.21", Max: nil})
+	return compat
 }
 
 // IsGoCompatible checks if a Go version satisfies the requirements for a sop version.
@@ -49,9 +46,11 @@ func GoCompatFor(sopVersion string) *GoCompat {
 // import "fmt"
 // fmt.Println(IsGoCompatible("1.22.0", "0.5.0"))
 // fmt.Println(IsGoCompatible("1.20.0", "0.5.0"))
+// fmt.Println(IsGoCompatible("1.21rc2", "0.5.0"))
 // // Output:
 // // true
 // // false
+// // false
 // ```
 func IsGoCompatible(goVersion string, sopVersion string) bool {
 	compat := GoCompatFor(sopVersion)
@@ -60,29 +59,39 @@ func IsGoCompatible(goVersion string, sopVersion string) bool {
 		return true
 	}
 
-	goMajor, goMinor, goPatch, _err0 := parseVersion(goVersion)
+	goV, _err0 := ParseGoVersion(goVersion)
 	if _err0 != nil {
 		return false
 	}
 
-	minMajor, minMinor, minPatch, _err1 := parseVersion(compat.Min)
+	minV, _err1 := ParseGoVersion(compat.Min)
 	if _err1 != nil {
 		return false
 	}
 
 	// Check minimum
-	if (!versionAtLeast(goMajor, goMinor, goPatch, minMajor, minMinor, minPatch)) {
+	if goV.Compare(minV) < 0 {
 		return false
 	}
 
 	// Check maximum if set
 	if compat.Max != nil {
-		maxMajor, maxMinor, maxPatch, _err2 := parseVersion((*compat.Max))
+		maxV, _err2 := ParseGoVersion((*compat.Max))
 		if _err2 != nil {
 			return false
 		}
 
-		if (!versionAtLeast(maxMajor, maxMinor, maxPatch, goMajor, goMinor, goPatch)) {
+		if goV.Compare(maxV) > 0 {
+			return false
+		}
+	}
+
+	for _, bad := range compat.Bad {
+		badV, _err3 := ParseGoVersion(bad)
+		if _err3 != nil {
+			continue
+		}
+		if goV.Compare(badV) == 0 {
 			return false
 		}
 	}
@@ -104,10 +113,14 @@ func CompatMessage(sopVersion string) string {
 	if compat == nil {
 		return fmt.Sprintf("sop %s has unknown go requirements", sopVersion)
 	}
+	message := fmt.Sprintf("sop %s requires go %s or later", sopVersion, compat.Min)
 	if compat.Max != nil {
-		return fmt.Sprintf("sop %s requires go %s to %s", sopVersion, compat.Min, (*compat.Max))
+		message = fmt.Sprintf("sop %s requires go %s to %s", sopVersion, compat.Min, (*compat.Max))
 	}
-	return fmt.Sprintf("sop %s requires go %s or later", sopVersion, compat.Min)
+	if len(compat.Bad) > 0 {
+		message += fmt.Sprintf(" (except %s)", strings.Join(compat.Bad, ", "))
+	}
+	return message
 }
 
 func parseVersion(version string) (major int, minor int, patch int, err error) {
@@ -148,3 +161,75 @@ func versionAtLeast(aMajor int, aMinor int, aPatch int, bMajor int, bMinor int,
 	return aPatch >= bPatch
 }
 
+// CompareVersions compares two version strings.
+// Returns negative if a < b, zero if a == b, positive if a > b.
+// Prerelease suffixes ("1.23rc1", "0.6.0-beta2") sort before the release they precede.
+//
+// ```sop
+// import "fmt"
+// fmt.Println(CompareVersions("1.22.0", "1.21.0") > 0)
+// fmt.Println(CompareVersions("1.21.0", "1.22.0") < 0)
+// fmt.Println(CompareVersions("1.22.0", "1.22.0") == 0)
+// fmt.Println(CompareVersions("1.23rc1", "1.23.0") < 0)
+// // Output:
+// // true
+// // true
+// // true
+// // true
+// ```
+func CompareVersions(a string, b string) int {
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		aNum, aPre := splitPrerelease(aParts[i])
+		bNum, bPre := splitPrerelease(bParts[i])
+		if aNum != bNum {
+			return aNum - bNum
+		}
+		if aPre != bPre {
+			// A prerelease sorts before the release it precedes
+			if aPre == "" {
+				return 1
+			}
+			if bPre == "" {
+				return -1
+			}
+			return comparePrerelease(aPre, bPre)
+		}
+	}
+	return len(aParts) - len(bParts)
+}
+
+// splitPrerelease splits a version component like "23rc1" or "0-beta2" into
+// its number and prerelease suffix.
+func splitPrerelease(part string) (int, string) {
+	i := 0
+	for i < len(part) && part[i] >= '0' && part[i] <= '9' {
+		i++
+	}
+	var num int
+	fmt.Sscanf(part[:i], "%d", (&num))
+	return num, strings.TrimLeft(part[i:], "-")
+}
+
+// comparePrerelease orders suffixes like "beta2" and "rc10" by label, then number.
+func comparePrerelease(a string, b string) int {
+	aLabel, aNum := splitLabel(a)
+	bLabel, bNum := splitLabel(b)
+	if aLabel != bLabel {
+		return strings.Compare(aLabel, bLabel)
+	}
+	return aNum - bNum
+}
+
+func splitLabel(pre string) (string, int) {
+	i := len(pre)
+	for i > 0 && pre[i - 1] >= '0' && pre[i - 1] <= '9' {
+		i--
+	}
+	var num int
+	fmt.Sscanf(pre[i:], "%d", (&num))
+	return pre[:i], num
+}
+
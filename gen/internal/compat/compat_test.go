@@ -111,6 +111,9 @@ func TestIsGoCompatible(t *testing.T) {
 		{goVersion: "1.20.0", sopVersion: "0.5.0", want: false},
 		{goVersion: "1.19.0", sopVersion: "0.5.0", want: false},
 		{goVersion: "2.0.0", sopVersion: "0.5.0", want: true},
+		{goVersion: "1.21rc1", sopVersion: "0.5.0", want: false},
+		{goVersion: "1.22rc1", sopVersion: "0.5.0", want: true},
+		{goVersion: "1.20", sopVersion: "0.5.0", want: false},
 	}
 
 	for _, tt := range tests {
@@ -138,3 +141,188 @@ func TestCompatMessage(t *testing.T) {
 	}
 }
 
+func TestParseGoVersion(t *testing.T) {
+	tests := []struct {
+		input    string
+		want     string
+		isPrefix bool
+		wantErr  bool
+	}{
+		{input: "1.22.0", want: "1.22.0", isPrefix: false, wantErr: false},
+		{input: "go1.22.3", want: "1.22.3", isPrefix: false, wantErr: false},
+		{input: "1.22", want: "1.22.0", isPrefix: true, wantErr: false},
+		{input: "1.20", want: "1.20", isPrefix: true, wantErr: false},
+		{input: "1.20.0", want: "1.20", isPrefix: false, wantErr: false},
+		{input: "1.23rc1", want: "1.23rc1", isPrefix: false, wantErr: false},
+		{input: "go1.22beta2", want: "1.22beta2", isPrefix: false, wantErr: false},
+		{input: "1.21.0rc1", want: "", isPrefix: false, wantErr: true},
+		{input: "1.23rc", want: "", isPrefix: false, wantErr: true},
+		{input: "1", want: "", isPrefix: false, wantErr: true},
+		{input: "invalid", want: "", isPrefix: false, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseGoVersion(tt.input)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseGoVersion(%q) expected error, got nil", tt.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseGoVersion(%q) unexpected error: %v", tt.input, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("ParseGoVersion(%q).String() = %q, want %q", tt.input, got.String(), tt.want)
+		}
+		if got.IsPrefix() != tt.isPrefix {
+			t.Errorf("ParseGoVersion(%q).IsPrefix() = %v, want %v", tt.input, got.IsPrefix(), tt.isPrefix)
+		}
+	}
+}
+
+func TestGoVersionCompare(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		want int
+	}{
+		{a: "1.22.0", b: "1.21.0", want: 1},
+		{a: "1.21.0", b: "1.21", want: 0},
+		{a: "1.20", b: "1.20.0", want: 0},
+		{a: "1.20.1", b: "1.20", want: 1},
+		{a: "1.21rc1", b: "1.21.0", want: -1},
+		{a: "1.21rc2", b: "1.21rc1", want: 1},
+		{a: "1.21beta1", b: "1.21rc1", want: -1},
+		{a: "1.21rc1", b: "1.20.7", want: 1},
+	}
+
+	for _, tt := range tests {
+		a, _err0 := ParseGoVersion(tt.a)
+		if _err0 != nil {
+			err := _err0
+			t.Fatalf("ParseGoVersion(%q) failed: %v", tt.a, err)
+		}
+		b, _err1 := ParseGoVersion(tt.b)
+		if _err1 != nil {
+			err := _err1
+			t.Fatalf("ParseGoVersion(%q) failed: %v", tt.b, err)
+		}
+		got := a.Compare(b)
+		if (got > 0) != (tt.want > 0) || (got < 0) != (tt.want < 0) {
+			t.Errorf("Compare(%q, %q) = %d, want sign of %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+const testManifest = `schema = 1
+
+[[sop]]
+from = "0.0.0"
+go_min = "1.21"
+
+[[sop]]
+from = "0.6.0"
+go_min = "1.23"
+go_max = "1.25.9"
+go_bad = ["1.23.1"]
+`
+
+func TestManifestFor(t *testing.T) {
+	m, _err0 := ParseManifest(testManifest)
+	if _err0 != nil {
+		err := _err0
+		t.Fatalf("ParseManifest failed: %v", err)
+	}
+
+	tests := []struct {
+		sopVersion string
+		wantFrom   string
+	}{
+		{sopVersion: "0.5.1", wantFrom: "0.0.0"},
+		{sopVersion: "0.6.0", wantFrom: "0.6.0"},
+		{sopVersion: "v0.7.2", wantFrom: "0.6.0"},
+		{sopVersion: "0.6.0-beta.1", wantFrom: "0.6.0"},
+		{sopVersion: "nightly-2025-01-01", wantFrom: ""},
+	}
+
+	for _, tt := range tests {
+		got := m.For(tt.sopVersion)
+		gotFrom := ""
+		if got != nil {
+			gotFrom = got.From
+		}
+		if gotFrom != tt.wantFrom {
+			t.Errorf("For(%q) = entry from %q, want %q", tt.sopVersion, gotFrom, tt.wantFrom)
+		}
+	}
+}
+
+func TestParseManifestErrors(t *testing.T) {
+	tests := []string{
+		"schema = 2\n",
+		"schema = 1\n[[sop]]\nfrom = \"next\"\ngo_min = \"1.21\"\n",
+		"schema = 1\n[[sop]]\nfrom = \"0.6.0\"\ngo_min = \"latest\"\n",
+		"schema = 1\n[[sop]]\nfrom = \"0.6.0\"\ngo_min = \"1.23\"\ngo_bad = [\"1.23.x\"]\n",
+		"schema = 1\n[[sop]\n",
+	}
+
+	for _, data := range tests {
+		if _, err := ParseManifest(data); err == nil {
+			t.Errorf("ParseManifest(%q) expected error, got nil", data)
+		}
+	}
+}
+
+func TestDefaultManifest(t *testing.T) {
+	m, _err0 := ParseManifest(defaultManifest)
+	if _err0 != nil {
+		err := _err0
+		t.Fatalf("built-in manifest is invalid: %v", err)
+	}
+	if m.For("0.0.1") == nil {
+		t.Error("built-in manifest should cover every sop release")
+	}
+}
+
+func TestManifestCompatibility(t *testing.T) {
+	m, _err0 := ParseManifest(testManifest)
+	if _err0 != nil {
+		err := _err0
+		t.Fatalf("ParseManifest failed: %v", err)
+	}
+	defaultCurrent := current
+	current = func() Manifest {
+		return m
+	}
+	defer func() {
+		current = defaultCurrent
+	}()
+
+	tests := []struct {
+		goVersion  string
+		sopVersion string
+		want       bool
+	}{
+		{goVersion: "1.22.0", sopVersion: "0.5.0", want: true},
+		{goVersion: "1.22.0", sopVersion: "0.6.0", want: false},
+		{goVersion: "1.23.0", sopVersion: "0.6.0", want: true},
+		{goVersion: "1.23.1", sopVersion: "0.6.0", want: false},
+		{goVersion: "1.25.9", sopVersion: "0.6.0", want: true},
+		{goVersion: "1.26.0", sopVersion: "0.6.0", want: false},
+	}
+
+	for _, tt := range tests {
+		got := IsGoCompatible(tt.goVersion, tt.sopVersion)
+		if got != tt.want {
+			t.Errorf("IsGoCompatible(%q, %q) = %v, want %v", tt.goVersion, tt.sopVersion, got, tt.want)
+		}
+	}
+
+	want := "sop 0.6.0 requires go 1.23 to 1.25.9 (except 1.23.1)"
+	if got := CompatMessage("0.6.0"); got != want {
+		t.Errorf("CompatMessage(0.6.0) = %q, want %q", got, want)
+	}
+}
+
@@ -0,0 +1,156 @@
+//soppo:generated v1
+package compat
+
+import "fmt"
+import "strconv"
+import "strings"
+
+// GoVersion is a parsed Go release version.
+//
+// Go has used several naming schemes over the years: releases before 1.21
+// were named without a patch component ("go1.20"), later ones always carry
+// one ("go1.21.0"), and prereleases append a suffix to the minor version
+// ("go1.23rc1", "go1.22beta2").
+type GoVersion struct {
+	Major int
+	Minor int
+	Patch int
+	HasPatch bool
+	Pre string
+	PreNum int
+}
+
+// Whether the patch component was written out
+// Prerelease kind ("beta" or "rc"), empty for final releases
+// Prerelease number, e.g. 1 for rc1
+
+// ParseGoVersion parses a Go version in any of its historical forms, with or
+// without a leading "go".
+//
+// ```sop
+// import "fmt"
+// v := ParseGoVersion("go1.23rc1") ? err {
+// 	panic(err)
+// }
+// fmt.Println(v.Minor, v.Pre, v.PreNum)
+// // Output:
+// // 23 rc 1
+// ```
+func ParseGoVersion(version string) (GoVersion, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(version), "go")
+	v := GoVersion{}
+
+	for _, pre := range []string{"beta", "rc"} {
+		if i := strings.Index(s, pre); i >= 0 {
+			n, _err0 := strconv.Atoi(s[i + len(pre):])
+			if _err0 != nil {
+				return GoVersion{}, fmt.Errorf("invalid go version: %s", version)
+			}
+			v.Pre = pre
+			v.PreNum = n
+			s = s[:i]
+			break
+		}
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return GoVersion{}, fmt.Errorf("invalid go version: %s", version)
+	}
+
+	nums := []int{0, 0, 0}
+	for i, part := range parts {
+		n, _err1 := strconv.Atoi(part)
+		if _err1 != nil {
+			return GoVersion{}, fmt.Errorf("invalid go version: %s", version)
+		}
+		nums[i] = n
+	}
+
+	v.Major = nums[0]
+	v.Minor = nums[1]
+	v.Patch = nums[2]
+	v.HasPatch = len(parts) == 3
+
+	// Prereleases precede the .0 release, so "1.21.0rc1" is not a thing
+	if v.Pre != "" && v.HasPatch {
+		return GoVersion{}, fmt.Errorf("invalid go version: %s", version)
+	}
+
+	return v, nil
+}
+
+// IsPrerelease reports whether the version is a beta or release candidate.
+func (v GoVersion) IsPrerelease() bool {
+	return v.Pre != ""
+}
+
+// IsPrefix reports whether the version names a release line ("1.22") rather
+// than a single release. Prereleases are always exact.
+func (v GoVersion) IsPrefix() bool {
+	return (!v.HasPatch) && (!v.IsPrerelease())
+}
+
+// String returns the version the way Go names the release, without the
+// "go" prefix. Final releases before 1.21 drop a zero patch.
+//
+// ```sop
+// import "fmt"
+// v := ParseGoVersion("1.20.0") ? err {
+// 	panic(err)
+// }
+// fmt.Println(v)
+// // Output:
+// // 1.20
+// ```
+func (v GoVersion) String() string {
+	if v.IsPrerelease() {
+		return fmt.Sprintf("%d.%d%s%d", v.Major, v.Minor, v.Pre, v.PreNum)
+	}
+	if v.Patch == 0 && v.Major == 1 && v.Minor < 21 {
+		return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+	}
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Compare compares two Go versions.
+// Returns negative if v < other, zero if equal, positive if v > other.
+// Prereleases sort before the release they precede, betas before release candidates.
+//
+// ```sop
+// import "fmt"
+// rc := ParseGoVersion("1.21rc2") ? err {
+// 	panic(err)
+// }
+// final := ParseGoVersion("1.21.0") ? err {
+// 	panic(err)
+// }
+// fmt.Println(rc.Compare(final) < 0)
+// // Output:
+// // true
+// ```
+func (v GoVersion) Compare(other GoVersion) int {
+	if v.Major != other.Major || v.Minor != other.Minor || v.Patch != other.Patch {
+		if versionAtLeast(v.Major, v.Minor, v.Patch, other.Major, other.Minor, other.Patch) {
+			return 1
+		}
+		return -1
+	}
+
+	if v.Pre != other.Pre {
+		return preRank(v.Pre) - preRank(other.Pre)
+	}
+	return v.PreNum - other.PreNum
+}
+
+func preRank(pre string) int {
+	switch pre {
+	case "beta":
+		return 0
+	case "rc":
+		return 1
+	default:
+		return 2
+	}
+}
+
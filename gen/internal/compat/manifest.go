@@ -0,0 +1,163 @@
+//soppo:generated v1
+package compat
+
+import "errors"
+import "fmt"
+import "io/fs"
+import "os"
+import "strings"
+import "sync"
+import "github.com/BurntSushi/toml"
+import "github.com/halcyonnouveau/sopmod/gen/internal/paths"
+
+// ManifestSchema is the manifest format this sopmod understands
+const ManifestSchema = 1
+
+// defaultManifest is built into sopmod and used until a refreshed or
+// hand-written manifest exists. Soppo releases publish the current one as
+// compat.toml.
+const defaultManifest = `# Go versions each sop release works with. An entry covers sop releases
+# from its version up to the next entry's.
+schema = 1
+
+[[sop]]
+from = "0.0.0"
+go_min = "1.21"
+`
+
+// Manifest lists the Go versions each range of sop releases works with
+type Manifest struct {
+	Schema int `toml:"schema"`
+	Sop []Entry `toml:"sop"`
+}
+
+// Entry holds the Go requirements of sop releases from From onwards
+type Entry struct {
+	From string `toml:"from"`
+	GoMin string `toml:"go_min"`
+	GoMax string `toml:"go_max"`
+	GoBad []string `toml:"go_bad"`
+}
+
+// First sop version the entry covers
+// Oldest Go that works (inclusive)
+// Newest Go that works (inclusive), empty for no bound
+// Go releases known to break sop, e.g. through a compiler bug
+// ParseManifest decodes and validates a manifest.
+//
+// ```sop
+// import "fmt"
+// m := ParseManifest("schema = 1\n[[sop]]\nfrom = \"0.6.0\"\ngo_min = \"1.23\"\ngo_bad = [\"1.23.1\"]\n") ? err {
+// 	panic(err)
+// }
+// if e := m.For("0.6.2"); e != nil {
+// 	fmt.Println(e.GoMin)
+// }
+// fmt.Println(m.For("0.5.0") == nil)
+// // Output:
+// // 1.23
+// // true
+// ```
+func ParseManifest(data string) (Manifest, error) {
+	var m Manifest
+	_, _err0 := toml.Decode(data, (&m))
+	if _err0 != nil {
+		return Manifest{}, _err0
+	}
+
+	if m.Schema != ManifestSchema {
+		return Manifest{}, fmt.Errorf("unsupported compat manifest schema %d, this sopmod reads schema %d", m.Schema, ManifestSchema)
+	}
+
+	for _, e := range m.Sop {
+		_, _, _, err := parseVersion(e.From)
+		if err != nil {
+			return Manifest{}, fmt.Errorf("invalid sop version '%s' in compat manifest", e.From)
+		}
+		goVersions := append([]string{e.GoMin}, e.GoBad...)
+		if e.GoMax != "" {
+			goVersions = append(goVersions, e.GoMax)
+		}
+		for _, v := range goVersions {
+			_, _err1 := ParseGoVersion(v)
+			if _err1 != nil {
+				return Manifest{}, fmt.Errorf("invalid go version '%s' for sop %s in compat manifest", v, e.From)
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// For returns the entry covering a sop version, nil if none does or the
+// version isn't a release number. Prereleases count as the release they lead
+// up to, so 0.6.0-beta.1 needs what 0.6.0 needs.
+//soppo:nilable : 0
+func (m Manifest) For(sopVersion string) *Entry {
+	version, _, _ := strings.Cut(strings.TrimPrefix(sopVersion, "v"), "-")
+	major, minor, patch, _err0 := parseVersion(version)
+	if _err0 != nil {
+		return nil
+	}
+
+	var best *Entry
+	var bestMajor, bestMinor, bestPatch int
+	for i := range m.Sop {
+		fromMajor, fromMinor, fromPatch, _err1 := parseVersion(m.Sop[i].From)
+		if _err1 != nil {
+			continue
+		}
+		if (!versionAtLeast(major, minor, patch, fromMajor, fromMinor, fromPatch)) {
+			continue
+		}
+		if best == nil || versionAtLeast(fromMajor, fromMinor, fromPatch, bestMajor, bestMinor, bestPatch) {
+			best = (&m.Sop[i])
+			bestMajor, bestMinor, bestPatch = fromMajor, fromMinor, fromPatch
+		}
+	}
+	return best
+}
+
+// LoadManifest reads the manifest the compat functions use: compat.toml in
+// the sopmod root if there is one, else the one `sopmod compat refresh`
+// fetched, else the built-in one. Also returns the file it came from, empty
+// for the built-in manifest.
+func LoadManifest() (Manifest, string, error) {
+	for _, path := range []string{paths.CompatFile(), paths.CompatCacheFile()} {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return Manifest{}, path, err
+		}
+		m, _err0 := ParseManifest(string(data))
+		if _err0 != nil {
+			err := _err0
+			return Manifest{}, path, fmt.Errorf("%s: %w", path, err)
+		}
+		return m, path, nil
+	}
+
+	m, _err1 := ParseManifest(defaultManifest)
+	if _err1 != nil {
+		return Manifest{}, "", _err1
+	}
+	return m, "", nil
+}
+
+// current returns the manifest, loading it on first use. A broken manifest
+// file falls back to the built-in one; `sopmod doctor` reports the error.
+var current = sync.OnceValue(func() Manifest {
+	m, _, err := LoadManifest()
+	if err != nil {
+		var _err0 error
+		m, _err0 = ParseManifest(defaultManifest)
+		if _err0 != nil {
+			parseErr := _err0
+			panic("built-in compat manifest is invalid: " + parseErr.Error())
+		}
+	}
+	return m
+})
+
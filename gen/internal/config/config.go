@@ -1,8 +1,13 @@
 //soppo:generated v1
 package config
 
+import "errors"
+import "fmt"
+import "io/fs"
 import "os"
 import "path/filepath"
+import "slices"
+import "strings"
 import "github.com/BurntSushi/toml"
 import "github.com/halcyonnouveau/sopmod/gen/internal/paths"
 
@@ -10,6 +15,48 @@ import "github.com/halcyonnouveau/sopmod/gen/internal/paths"
 type Config struct {
 	DefaultSop *string `toml:"default_sop,omitempty"`
 	DefaultGo *string `toml:"default_go,omitempty"`
+	SopChannel *string `toml:"sop_channel,omitempty"`
+	Channels map[string]string `toml:"channels,omitempty"`
+	DefaultToolchain *string `toml:"default_toolchain,omitempty"`
+	Toolchains map[string]Toolchain `toml:"toolchains,omitempty"`
+	TrackProjects *bool `toml:"track_projects,omitempty"`
+	CompatCheck *string `toml:"compat_check,omitempty"`
+	Network *Network `toml:"network,omitempty"`
+}
+
+// Channel the default sop follows on `sopmod update`, nil for a fixed version
+// Last installed version on each channel, consulted by the shim for channel pins
+// Toolchain used instead of default_sop/default_go when set
+// Whether the shim records projects in ~/.sopmod/projects, on unless set to false
+// What the shim does when go doesn't suit sop: warn (default), error or off
+// How sopmod reaches go.dev and GitHub, nil for the environment's proxy and the system roots
+
+// Values for compat_check
+const (
+	CompatWarn  = "warn"
+	CompatError = "error"
+	CompatOff   = "off"
+)
+
+// Network is the [network] table of config.toml, applied to every request
+// sopmod makes
+type Network struct {
+	Proxy string `toml:"proxy,omitempty"`
+	NoProxy []string `toml:"no_proxy,omitempty"`
+	CACerts []string `toml:"ca_certs,omitempty"`
+	InsecureSkipVerify bool `toml:"insecure_skip_verify,omitempty"`
+}
+
+// Proxy URL, credentials included; $VARS are expanded. Empty uses HTTPS_PROXY and friends
+// Hosts, domains and CIDRs that skip the proxy
+// PEM files trusted on top of the system roots
+// Skip TLS certificate verification, for local mirrors only
+
+// Toolchain is a named pairing of a sop version with a specific Go, defined
+// under [toolchains.<name>] in config.toml
+type Toolchain struct {
+	Sop string `toml:"sop"`
+	Go string `toml:"go"`
 }
 
 // Load loads config from ~/.sopmod/config.toml, or returns default if not found
@@ -32,6 +79,43 @@ func LoadFrom(path string) (Config, error) {
 	return config, nil
 }
 
+// SetChannel records the version a channel currently resolves to
+func (c *Config) SetChannel(channel string, version string) {
+	if c.Channels == nil {
+		c.Channels = map[string]string{}
+	}
+	c.Channels[channel] = version
+}
+
+// FindToolchain looks up a toolchain by name. Both halves of the pairing must be set.
+func (c *Config) FindToolchain(name string) (Toolchain, error) {
+	tc, ok := c.Toolchains[name]
+	if (!ok) {
+		return Toolchain{}, fmt.Errorf("unknown toolchain '%s'. Define it under [toolchains.%s] in %s", name, name, paths.ConfigPath())
+	}
+	if tc.Sop == "" || tc.Go == "" {
+		return Toolchain{}, fmt.Errorf("toolchain '%s' must set both sop and go", name)
+	}
+	return tc, nil
+}
+
+// TracksProjects reports whether the shim should record the projects it runs in
+func (c *Config) TracksProjects() bool {
+	return c.TrackProjects == nil || (*c.TrackProjects)
+}
+
+// CompatCheckMode returns compat_check, defaulting to warn
+func (c *Config) CompatCheckMode() (string, error) {
+	if c.CompatCheck == nil {
+		return CompatWarn, nil
+	}
+	switch (*c.CompatCheck) {
+	case CompatWarn, CompatError, CompatOff:
+		return (*c.CompatCheck), nil
+	}
+	return "", fmt.Errorf("invalid compat_check '%s' in config.toml. Use 'warn', 'error' or 'off'", (*c.CompatCheck))
+}
+
 // Save saves config to ~/.sopmod/config.toml
 func (c *Config) Save() error {
 	path := paths.ConfigPath()
@@ -59,16 +143,134 @@ func (c *Config) SaveTo(path string) error {
 type ProjectConfig struct {
 	Go *string `toml:"go,omitempty"`
 	Sop *string `toml:"sop,omitempty"`
+	Toolchain *string `toml:"toolchain,omitempty"`
 }
 
-// LoadProjectConfig loads project config from sop.mod in the given directory
+// Named toolchain from config.toml, overridden by go/sop pins
+
+// LoadProjectConfig loads project config from sop.mod in the given directory.
+// Use LoadProjectFile to also get the rest of the file and its warnings.
 func LoadProjectConfig(dir string) (*ProjectConfig, error) {
-	path := filepath.Join(dir, "sop.mod")
-	var config ProjectConfig
-	_, _err0 := toml.DecodeFile(path, (&config))
+	project, _err0 := LoadProjectFile(filepath.Join(dir, "sop.mod"))
 	if _err0 != nil {
 		return nil, _err0
 	}
-	return (&config), nil
+	return (&project.Config), nil
+}
+
+// Wanted returns the sop and go versions the pins ask for, with a toolchain
+// named in the project filling in whichever isn't pinned directly. Either is
+// empty when it's left to the defaults.
+func (p *ProjectConfig) Wanted(cfg Config) (string, string, error) {
+	var wantSop, wantGo string
+	if p.Toolchain != nil {
+		tc, _err0 := cfg.FindToolchain((*p.Toolchain))
+		if _err0 != nil {
+			return "", "", _err0
+		}
+		wantSop = tc.Sop
+		wantGo = tc.Go
+	}
+	if p.Sop != nil {
+		wantSop = (*p.Sop)
+	}
+	if p.Go != nil {
+		wantGo = (*p.Go)
+	}
+	return wantSop, wantGo, nil
+}
+
+// LoadProjects returns the version file paths recorded in ~/.sopmod/projects
+func LoadProjects() []string {
+	projects, _err0 := LoadProjectsFrom(paths.ProjectsPath())
+	if _err0 != nil {
+		return nil
+	}
+	return projects
+}
+
+// LoadProjectsFrom reads a project registry, one version file path per line.
+// A missing registry has no projects, and a path listed twice counts once.
+func LoadProjectsFrom(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	projects := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && (!slices.Contains(projects, line)) {
+			projects = append(projects, line)
+		}
+	}
+	return projects, nil
+}
+
+// SaveProjects replaces the registry in ~/.sopmod/projects
+func SaveProjects(projects []string) error {
+	return SaveProjectsTo(paths.ProjectsPath(), projects)
+}
+
+// SaveProjectsTo writes a project registry to a specific path. The new
+// registry is written beside it and renamed over it, so a shim reading it
+// never sees half of one.
+func SaveProjectsTo(path string, projects []string) error {
+	dir := filepath.Dir(path)
+	_err0 := os.MkdirAll(dir, 0o755)
+	if _err0 != nil {
+		return _err0
+	}
+	content := ""
+	for _, project := range projects {
+		content += project + "\n"
+	}
+
+	tmp, _err1 := os.CreateTemp(dir, filepath.Base(path) + ".tmp-*")
+	if _err1 != nil {
+		return _err1
+	}
+	defer os.Remove(tmp.Name())
+	_, err := tmp.WriteString(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// AddProject records a version file path in ~/.sopmod/projects
+func AddProject(project string) error {
+	return AddProjectTo(paths.ProjectsPath(), project)
+}
+
+// AddProjectTo records a version file path in a specific registry, once.
+// Every shim run calls this, so it appends rather than rewriting the registry
+// and can't drop a project another sopmod adds at the same time. Two adding
+// the same project both append it, which LoadProjectsFrom reads as one.
+func AddProjectTo(path string, project string) error {
+	projects, _err0 := LoadProjectsFrom(path)
+	if _err0 != nil {
+		return _err0
+	}
+	if slices.Contains(projects, project) {
+		return nil
+	}
+
+	_err1 := os.MkdirAll(filepath.Dir(path), 0o755)
+	if _err1 != nil {
+		return _err1
+	}
+	f, _err2 := os.OpenFile(path, os.O_CREATE | os.O_WRONLY | os.O_APPEND, 0o644)
+	if _err2 != nil {
+		return _err2
+	}
+	defer f.Close()
+	_, err := f.WriteString(project + "\n")
+	return err
 }
 
@@ -0,0 +1,89 @@
+//soppo:generated v1
+package config
+
+import "errors"
+import "fmt"
+import "io/fs"
+import "os"
+import "path/filepath"
+import "github.com/BurntSushi/toml"
+import "github.com/halcyonnouveau/sopmod/gen/internal/compat"
+import "github.com/halcyonnouveau/sopmod/gen/internal/paths"
+
+// Overrides are personal pins set with `sopmod local`, keyed by directory.
+// They live in the sopmod root rather than the project, so they never end up
+// in a commit.
+type Overrides map[string]ProjectConfig
+
+// LoadOverrides reads ~/.sopmod/local.toml
+func LoadOverrides() (Overrides, error) {
+	return LoadOverridesFrom(paths.LocalFile())
+}
+
+// LoadOverridesFrom reads overrides from a specific path. A missing file has none.
+func LoadOverridesFrom(path string) (Overrides, error) {
+	overrides := Overrides{}
+	_, err := toml.DecodeFile(path, (&overrides))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Overrides{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for dir, pins := range overrides {
+		if pins.Go != nil {
+			_, _err0 := compat.ParseGoVersion((*pins.Go))
+			if _err0 != nil {
+				return nil, fmt.Errorf("invalid go version '%s' for %s in %s", (*pins.Go), dir, path)
+			}
+		}
+		if pins.Sop != nil {
+			_err1 := validateSopPin((*pins.Sop))
+			if _err1 != nil {
+				err := _err1
+				return nil, fmt.Errorf("%w for %s in %s", err, dir, path)
+			}
+		}
+	}
+	return overrides, nil
+}
+
+// For returns the override covering dir, set on dir itself or its nearest
+// parent with one, and the directory it was set on
+//soppo:nilable : 1
+func (o Overrides) For(dir string) (string, *ProjectConfig) {
+	current := filepath.Clean(dir)
+	for {
+		if pins, ok := o[current]; ok {
+			return current, (&pins)
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return "", nil
+		}
+		current = parent
+	}
+}
+
+// Save writes the overrides to ~/.sopmod/local.toml
+func (o Overrides) Save() error {
+	return o.SaveTo(paths.LocalFile())
+}
+
+// SaveTo writes the overrides to a specific path
+func (o Overrides) SaveTo(path string) error {
+	_err0 := os.MkdirAll(filepath.Dir(path), 0o755)
+	if _err0 != nil {
+		return _err0
+	}
+	f, _err1 := os.Create(path)
+	if _err1 != nil {
+		return _err1
+	}
+	defer f.Close()
+
+	encoder := toml.NewEncoder(f)
+	return encoder.Encode(o)
+}
+
@@ -0,0 +1,317 @@
+//soppo:generated v1
+package config
+
+import "errors"
+import "fmt"
+import "io/fs"
+import "os"
+import "slices"
+import "strings"
+import "github.com/BurntSushi/toml"
+import "github.com/halcyonnouveau/sopmod/gen/internal/compat"
+
+// projectKeys are the sop.mod keys sopmod reads
+var projectKeys = []string{"go", "sop", "toolchain"}
+
+// compilerKeys are the top-level sop.mod keys the Soppo compiler reads. Tables
+// are always left to the compiler.
+var compilerKeys = []string{"include", "output"}
+
+// ProjectFile is a sop.mod as written. sopmod only reads the go, sop and
+// toolchain pins, but the file is shared with the Soppo compiler, so the rest
+// of the document is kept rather than dropped.
+type ProjectFile struct {
+	Path string
+	Config ProjectConfig
+	Keys map[string]any
+	Warnings []string
+	Workspace *Workspace //soppo:nilable
+}
+// Keys: Every top-level key and table, sopmod's or the compiler's
+// Warnings: Unknown or misspelled keys
+// Workspace: The [workspace] table of a monorepo's root sop.mod
+
+// LoadProjectFile reads and validates the sop.mod at path
+func LoadProjectFile(path string) (*ProjectFile, error) {
+	data, _err0 := os.ReadFile(path)
+	if _err0 != nil {
+		return nil, _err0
+	}
+	return parseProjectFile(path, string(data))
+}
+
+// parseProjectFile decodes a sop.mod, checks its pins and warns about keys
+// neither sopmod nor the compiler knows
+func parseProjectFile(path string, text string) (*ProjectFile, error) {
+	p := (&ProjectFile{Path: path, Keys: map[string]any{}})
+	_, _err0 := toml.Decode(text, (&p.Config))
+	if _err0 != nil {
+		return nil, _err0
+	}
+	_, _err1 := toml.Decode(text, (&p.Keys))
+	if _err1 != nil {
+		return nil, _err1
+	}
+	var tables struct {
+		Workspace *Workspace `toml:"workspace"` //soppo:nilable
+	}
+	_, _err2 := toml.Decode(text, (&tables))
+	if _err2 != nil {
+		return nil, _err2
+	}
+	p.Workspace = tables.Workspace
+
+	if p.Config.Go != nil {
+		_, _err3 := compat.ParseGoVersion((*p.Config.Go))
+		if _err3 != nil {
+			return nil, fmt.Errorf("invalid go version '%s'", (*p.Config.Go))
+		}
+	}
+	if p.Config.Sop != nil {
+		_err4 := validateSopPin((*p.Config.Sop))
+		if _err4 != nil {
+			return nil, _err4
+		}
+	}
+	if p.Workspace != nil {
+		_err5 := p.Workspace.validate()
+		if _err5 != nil {
+			return nil, _err5
+		}
+	}
+
+	names := make([]string, 0, len(p.Keys))
+	for name := range p.Keys {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if slices.Contains(projectKeys, name) || slices.Contains(compilerKeys, name) || isTable(p.Keys[name]) {
+			continue
+		}
+		if suggestion := closestKey(name); suggestion != "" {
+			p.Warnings = append(p.Warnings, fmt.Sprintf("unknown key '%s', did you mean '%s'?", name, suggestion))
+		} else {
+			p.Warnings = append(p.Warnings, fmt.Sprintf("unknown key '%s'", name))
+		}
+	}
+	return p, nil
+}
+
+// ValidatePin checks a go or sop version the way pins in sop.mod are checked
+func ValidatePin(tool string, version string) error {
+	switch tool {
+	case "go":
+		_, _err0 := compat.ParseGoVersion(version)
+		if _err0 != nil {
+			return fmt.Errorf("invalid go version '%s'", version)
+		}
+		return nil
+	case "sop":
+		return validateSopPin(version)
+	}
+	return fmt.Errorf("unknown tool '%s'. Use 'go' or 'sop'", tool)
+}
+
+// validateSopPin accepts a version or prefix (0.5, 0.5.1, 0.6.0-beta.1), or a
+// name for a channel or a linked or git-built sop
+func validateSopPin(pin string) error {
+	if pin == "" || strings.ContainsAny(pin, "/\\ \t") {
+		return fmt.Errorf("invalid sop version '%s'", pin)
+	}
+	if pin[0] >= 'a' && pin[0] <= 'z' {
+		return nil
+	}
+
+	version, _, _ := strings.Cut(pin, "-")
+	parts := strings.Split(version, ".")
+	if len(parts) > 3 {
+		return fmt.Errorf("invalid sop version '%s'", pin)
+	}
+	for _, part := range parts {
+		if part == "" || strings.Trim(part, "0123456789") != "" {
+			return fmt.Errorf("invalid sop version '%s'", pin)
+		}
+	}
+	return nil
+}
+
+func isTable(value any) bool {
+	if _, ok := value.(map[string]any); ok {
+		return true
+	}
+	_, ok := value.([]map[string]any)
+	return ok
+}
+
+// closestKey returns the sopmod key name is likely a typo of, or empty
+func closestKey(name string) string {
+	for _, key := range projectKeys {
+		allowed := 1
+		if len(key) > 4 {
+			allowed = 2
+		}
+		if editDistance(name, key) <= allowed {
+			return key
+		}
+	}
+	return ""
+}
+
+// editDistance counts the single-character edits that turn a into b
+func editDistance(a string, b string) int {
+	prev := make([]int, len(b) + 1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		row := make([]int, len(b) + 1)
+		row[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i - 1] == b[j - 1] {
+				cost = 0
+			}
+			row[j] = min(prev[j] + 1, row[j - 1] + 1, prev[j - 1] + cost)
+		}
+		prev = row
+	}
+	return prev[len(b)]
+}
+
+// SetProjectPin sets a top-level string key in the sop.mod at path, creating
+// the file if needed. Only the key's line changes; comments, key order, tables
+// and keys sopmod doesn't know about are left exactly as written.
+func SetProjectPin(path string, key string, value string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && (!errors.Is(err, fs.ErrNotExist)) {
+		return err
+	}
+
+	updated := setTopLevelKey(string(data), key, value)
+
+	// Never leave a sop.mod behind that neither tool can read
+	_, _err0 := parseProjectFile(path, updated)
+	if _err0 != nil {
+		err := _err0
+		return fmt.Errorf("refusing to write %s: %w", path, err)
+	}
+	return os.WriteFile(path, []byte(updated), 0o644)
+}
+
+// setTopLevelKey rewrites `key = ...` above the first table, keeping its
+// indentation and trailing comment, or adds it after the last top-level key.
+//
+// ```sop
+// import "fmt"
+// fmt.Print(setTopLevelKey("# pins\nsop = \"0.5\" # stable\ninclude = [\"*.sop\"]\n", "sop", "0.6"))
+// // Output:
+// // # pins
+// // sop = "0.6" # stable
+// // include = ["*.sop"]
+// ```
+func setTopLevelKey(doc string, key string, value string) string {
+	lines := strings.Split(doc, "\n")
+	assignment := fmt.Sprintf("%s = %q", key, value)
+
+	insertAt := 0
+	depth := 0 // Open brackets of a multi-line array
+	for i, line := range lines {
+		code, comment := splitComment(line)
+		trimmed := strings.TrimSpace(code)
+		if depth > 0 {
+			depth += bracketDepth(code)
+			if depth <= 0 {
+				depth = 0
+				insertAt = i + 1
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "[") {
+			break
+		}
+		name, rest, ok := strings.Cut(trimmed, "=")
+		if (!ok) {
+			continue
+		}
+		insertAt = i + 1
+		depth = bracketDepth(rest)
+		if depth > 0 {
+			continue
+		}
+		depth = 0
+
+		if strings.Trim(strings.TrimSpace(name), "\"'") == key {
+			indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			if comment != "" {
+				comment = " " + comment
+			}
+			lines[i] = indent + assignment + comment
+			return strings.Join(lines, "\n")
+		}
+	}
+
+	if strings.TrimSpace(doc) == "" {
+		return assignment + "\n"
+	}
+	lines = slices.Insert(lines, insertAt, assignment)
+	return strings.Join(lines, "\n")
+}
+
+// splitComment splits a TOML line into its code and a trailing # comment
+func splitComment(line string) (string, string) {
+	idx := scanLine(line, func(rune) {})
+	if idx < 0 {
+		return line, ""
+	}
+	return line[:idx], line[idx:]
+}
+
+// bracketDepth counts the brackets a line opens minus those it closes
+func bracketDepth(code string) int {
+	depth := 0
+	scanLine(code, func(r rune) {
+		if r == '[' {
+			depth++
+		} else {
+			if r == ']' {
+				depth--
+			}
+		}
+	})
+	return depth
+}
+
+// scanLine calls onCode for each rune of a TOML line outside strings and
+// returns where a trailing # comment starts, or -1
+func scanLine(line string, onCode func(rune)) int {
+	quote := rune(0)
+	escaped := false
+	for i, r := range line {
+		if quote != 0 {
+			if escaped {
+				escaped = false
+			} else {
+				if r == '\\' && quote == '"' {
+					escaped = true
+				} else {
+					if r == quote {
+						quote = 0
+					}
+				}
+			}
+			continue
+		}
+		if r == '"' || r == '\'' {
+			quote = r
+		} else {
+			if r == '#' {
+				return i
+			} else {
+				onCode(r)
+			}
+		}
+	}
+	return -1
+}
+
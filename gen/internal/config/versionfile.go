@@ -0,0 +1,233 @@
+//soppo:generated v1
+package config
+
+import "errors"
+import "fmt"
+import "io/fs"
+import "os"
+import "path/filepath"
+import "slices"
+import "strings"
+import "github.com/halcyonnouveau/sopmod/gen/internal/compat"
+
+// VersionFiles are the files a project can pin versions in, highest
+// precedence first. The nearest directory with any of them decides, and
+// within that directory only the first one found is read.
+var VersionFiles = []string{"sop.mod", ".sop-version", ".tool-versions"}
+
+// toolVersionsNames maps .tool-versions tool names to the tool they pin.
+// asdf calls go golang, mise accepts either.
+var toolVersionsNames = map[string]string{
+	"sop": "sop",
+	"golang": "go",
+	"go": "go",
+}
+
+// LoadVersionFile reads a sop.mod, .sop-version or .tool-versions. Only a
+// sop.mod has Keys or Warnings.
+func LoadVersionFile(path string) (*ProjectFile, error) {
+	switch filepath.Base(path) {
+	case ".sop-version":
+		data, _err0 := os.ReadFile(path)
+		if _err0 != nil {
+			return nil, _err0
+		}
+		cfg, _err1 := parseSopVersion(string(data))
+		if _err1 != nil {
+			return nil, _err1
+		}
+		return (&ProjectFile{Path: path, Config: (*cfg)}), nil
+	case ".tool-versions":
+		data, _err2 := os.ReadFile(path)
+		if _err2 != nil {
+			return nil, _err2
+		}
+		cfg, _err3 := parseToolVersions(string(data))
+		if _err3 != nil {
+			return nil, _err3
+		}
+		return (&ProjectFile{Path: path, Config: (*cfg)}), nil
+	default:
+		return LoadProjectFile(path)
+	}
+}
+
+// LoadPins reads the go and sop pins from a sop.mod, .sop-version or
+// .tool-versions
+func LoadPins(path string) (*ProjectConfig, error) {
+	project, _err0 := LoadVersionFile(path)
+	if _err0 != nil {
+		return nil, _err0
+	}
+	return (&project.Config), nil
+}
+
+// SetPin sets the go or sop pin in a sop.mod, .sop-version or .tool-versions,
+// creating the file if needed and leaving the rest of it as written
+func SetPin(path string, tool string, version string) error {
+	switch filepath.Base(path) {
+	case ".sop-version":
+		if tool != "sop" {
+			return fmt.Errorf("%s only holds the sop version, pin %s in sop.mod or .tool-versions", path, tool)
+		}
+		_err0 := validateSopPin(version)
+		if _err0 != nil {
+			return _err0
+		}
+		return os.WriteFile(path, []byte(version + "\n"), 0o644)
+	case ".tool-versions":
+		data, err := os.ReadFile(path)
+		if err != nil && (!errors.Is(err, fs.ErrNotExist)) {
+			return err
+		}
+		updated := setToolVersion(string(data), tool, version)
+		_, _err1 := parseToolVersions(updated)
+		if _err1 != nil {
+			err := _err1
+			return fmt.Errorf("refusing to write %s: %w", path, err)
+		}
+		return os.WriteFile(path, []byte(updated), 0o644)
+	default:
+		return SetProjectPin(path, tool, version)
+	}
+}
+
+// BumpPin moves tool's pin in the version file at path up to latest, cut to
+// the pin's precision. It returns that version and how it compares with the
+// pin, and only writes the file when it's newer.
+func BumpPin(path string, tool string, pin string, latest string) (string, int, error) {
+	bumped, order := bumpSopPin(pin, latest)
+	if tool == "go" {
+		current, _err0 := compat.ParseGoVersion(pin)
+		if _err0 != nil {
+			return "", 0, fmt.Errorf("invalid go version '%s' in %s", pin, path)
+		}
+		bumped = keepPrecision(pin, latest)
+		newer, _err1 := compat.ParseGoVersion(bumped)
+		if _err1 != nil {
+			return "", 0, _err1
+		}
+		order = newer.Compare(current)
+	}
+	if order > 0 {
+		_err2 := SetPin(path, tool, bumped)
+		if _err2 != nil {
+			return "", 0, _err2
+		}
+	}
+	return bumped, order, nil
+}
+
+// bumpSopPin returns the latest sop release cut to the precision of pin, and
+// how that compares with pin. Pins starting with a letter name a toolchain,
+// a channel or a nightly rather than a release, so they never move.
+func bumpSopPin(pin string, latest string) (string, int) {
+	if pin == "" || (pin[0] >= 'a' && pin[0] <= 'z') {
+		return pin, 0
+	}
+	bumped := keepPrecision(pin, latest)
+	return bumped, compat.CompareVersions(bumped, pin)
+}
+
+// keepPrecision shortens version to as many parts as pin has, so bumping a
+// "1.22" pin gives "1.23" rather than "1.23.4"
+func keepPrecision(pin string, version string) string {
+	parts := strings.Split(version, ".")
+	n := strings.Count(pin, ".") + 1
+	if n < len(parts) {
+		return strings.Join(parts[:n], ".")
+	}
+	return version
+}
+
+// parseSopVersion reads a .sop-version: the sop version on its own line,
+// optionally with # comments
+func parseSopVersion(text string) (*ProjectConfig, error) {
+	for _, line := range strings.Split(text, "\n") {
+		code, _ := splitComment(line)
+		version := strings.TrimSpace(code)
+		if version == "" {
+			continue
+		}
+		_err0 := validateSopPin(version)
+		if _err0 != nil {
+			return nil, _err0
+		}
+		return (&ProjectConfig{Sop: (&version)}), nil
+	}
+	return nil, fmt.Errorf(".sop-version has no version in it")
+}
+
+// parseToolVersions reads the sop and go lines of a .tool-versions. Other
+// tools are skipped, fallback versions after the first are ignored and
+// "system" leaves the tool unpinned.
+//
+// ```sop
+// import "fmt"
+// cfg := parseToolVersions("nodejs 22.1.0\ngolang 1.23.4 1.22.8\nsop 0.5 # pinned for the release\n") ? err {
+// 	panic(err)
+// }
+// fmt.Println(*cfg.Go, *cfg.Sop)
+// // Output:
+// // 1.23.4 0.5
+// ```
+func parseToolVersions(text string) (*ProjectConfig, error) {
+	cfg := (&ProjectConfig{})
+	for _, line := range strings.Split(text, "\n") {
+		code, _ := splitComment(line)
+		fields := strings.Fields(code)
+		if len(fields) < 2 || fields[1] == "system" {
+			continue
+		}
+		version := fields[1]
+		switch toolVersionsNames[fields[0]] {
+		case "sop":
+			_err0 := validateSopPin(version)
+			if _err0 != nil {
+				return nil, _err0
+			}
+			cfg.Sop = (&version)
+		case "go":
+			_, _err1 := compat.ParseGoVersion(version)
+			if _err1 != nil {
+				return nil, fmt.Errorf("invalid go version '%s'", version)
+			}
+			cfg.Go = (&version)
+		}
+	}
+	return cfg, nil
+}
+
+// setToolVersion rewrites a tool's line in a .tool-versions, keeping the name
+// it's listed under, any fallback versions after the first and any trailing
+// comment, or adds it at the end
+func setToolVersion(doc string, tool string, version string) string {
+	lines := strings.Split(doc, "\n")
+	for i, line := range lines {
+		code, comment := splitComment(line)
+		fields := strings.Fields(code)
+		if len(fields) == 0 || toolVersionsNames[fields[0]] != tool {
+			continue
+		}
+		if comment != "" {
+			comment = " " + comment
+		}
+		fields = append([]string{fields[0], version}, fields[min(2, len(fields)):]...)
+		lines[i] = strings.Join(fields, " ") + comment
+		return strings.Join(lines, "\n")
+	}
+
+	name := tool
+	if tool == "go" {
+		name = "golang"
+	}
+	entry := name + " " + version
+	if strings.TrimSpace(doc) == "" {
+		return entry + "\n"
+	}
+	if lines[len(lines) - 1] == "" {
+		return strings.Join(slices.Insert(lines, len(lines) - 1, entry), "\n")
+	}
+	return doc + "\n" + entry + "\n"
+}
+
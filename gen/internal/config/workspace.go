@@ -0,0 +1,78 @@
+//soppo:generated v1
+package config
+
+import "fmt"
+import "os"
+import "path/filepath"
+import "slices"
+import "strings"
+
+// Workspace is the [workspace] table of a monorepo's root sop.mod. Members are
+// the directories of modules with their own version files, relative to the
+// root, and may be globs like services/*.
+type Workspace struct {
+	Members []string `toml:"members"`
+}
+
+// validate checks the member patterns before anything walks them
+func (w *Workspace) validate() error {
+	for _, member := range w.Members {
+		if member == "" || filepath.IsAbs(member) {
+			return fmt.Errorf("workspace member '%s' must be a directory relative to the workspace", member)
+		}
+		_, _err0 := filepath.Match(member, "")
+		if _err0 != nil {
+			return fmt.Errorf("invalid workspace member pattern '%s'", member)
+		}
+	}
+	return nil
+}
+
+// WorkspaceMembers returns the version file of every workspace member, in the
+// order listed, or nothing when p has no [workspace] table. A glob match
+// without a version file is skipped, but a member named outright must have one.
+func (p *ProjectFile) WorkspaceMembers() ([]string, error) {
+	if p.Workspace == nil {
+		return nil, nil
+	}
+
+	root := filepath.Dir(p.Path)
+	files := []string{}
+	for _, member := range p.Workspace.Members {
+		dirs := []string{filepath.Join(root, member)}
+		isGlob := strings.ContainsAny(member, "*?[")
+		if isGlob {
+			var _err0 error
+			dirs, _err0 = filepath.Glob(filepath.Join(root, member))
+			if _err0 != nil {
+				return nil, _err0
+			}
+		}
+
+		for _, dir := range dirs {
+			path := versionFileIn(dir)
+			if path == "" {
+				if (!isGlob) {
+					return nil, fmt.Errorf("workspace member '%s' has no %s", member, strings.Join(VersionFiles, ", "))
+				}
+				continue
+			}
+			if path != p.Path && (!slices.Contains(files, path)) {
+				files = append(files, path)
+			}
+		}
+	}
+	return files, nil
+}
+
+// versionFileIn returns the version file that decides dir's pins, or empty
+func versionFileIn(dir string) string {
+	for _, name := range VersionFiles {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && (!info.IsDir()) {
+			return path
+		}
+	}
+	return ""
+}
+
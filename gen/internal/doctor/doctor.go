@@ -0,0 +1,456 @@
+//soppo:generated v1
+package doctor
+
+import "errors"
+import "fmt"
+import "io/fs"
+import "os"
+import "path/filepath"
+import "runtime"
+import "strings"
+import "github.com/halcyonnouveau/sopmod/gen/internal/compat"
+import "github.com/halcyonnouveau/sopmod/gen/internal/config"
+import "github.com/halcyonnouveau/sopmod/gen/internal/install"
+import "github.com/halcyonnouveau/sopmod/gen/internal/paths"
+import "github.com/halcyonnouveau/sopmod/gen/internal/shim"
+
+// Status is the outcome of a single check
+type Status string
+
+const (
+	Pass Status = "pass"
+	Warn Status = "warn"
+	Fail Status = "fail"
+)
+
+// Check is the result of one diagnostic
+type Check struct {
+	Name string `json:"name"`
+	Status Status `json:"status"`
+	Message string `json:"message"`
+	Hint string `json:"hint,omitempty"`
+}
+
+// Command or change that fixes the problem, empty when passing
+
+// Run runs every diagnostic for the running sopmod version and returns the results in order
+func Run(version string) []Check {
+	checks := []Check{checkPath(os.Getenv("PATH"), paths.BinDir())}
+	checks = append(checks, checkShims(version)...)
+
+	cfg, cfgCheck := checkConfig(paths.ConfigPath())
+	checks = append(checks, cfgCheck)
+	checks = append(checks, checkNetwork(cfg)...)
+	checks = append(checks, checkCompat())
+	checks = append(checks, checkProject())
+	checks = append(checks, checkWorkspace(cfg)...)
+	checks = append(checks, checkDefaults(cfg)...)
+	checks = append(checks, checkInstalls()...)
+	return checks
+}
+
+// checkCompat makes sure the Go compatibility manifest in use can be read
+func checkCompat() Check {
+	_, path, err := compat.LoadManifest()
+	if err != nil {
+		return Check{
+			Name: "compat",
+			Status: Fail,
+			Message: fmt.Sprintf("%s, using the built-in go compatibility manifest", err),
+			Hint: fmt.Sprintf("fix or delete %s", path),
+		}
+	}
+	if path == "" {
+		return Check{Name: "compat", Status: Pass, Message: "using the built-in go compatibility manifest"}
+	}
+	return Check{Name: "compat", Status: Pass, Message: fmt.Sprintf("using the go compatibility manifest in %s", path)}
+}
+
+// checkPath makes sure binDir is on PATH ahead of any other sop binary
+func checkPath(pathVar string, binDir string) Check {
+	shadow := ""
+	for _, dir := range filepath.SplitList(pathVar) {
+		if dir == "" {
+			continue
+		}
+		if filepath.Clean(dir) == filepath.Clean(binDir) {
+			if shadow != "" {
+				return Check{
+					Name: "path",
+					Status: Fail,
+					Message: fmt.Sprintf("%s comes before %s on PATH and shadows the sopmod shim", shadow, binDir),
+					Hint: fmt.Sprintf("move %s to the front of PATH", binDir),
+				}
+			}
+			return Check{Name: "path", Status: Pass, Message: fmt.Sprintf("%s is on PATH ahead of other sop binaries", binDir)}
+		}
+		candidate := filepath.Join(dir, exeName("sop"))
+		if shadow == "" && fileExists(candidate) {
+			shadow = candidate
+		}
+	}
+
+	return Check{
+		Name: "path",
+		Status: Fail,
+		Message: fmt.Sprintf("%s is not on PATH", binDir),
+		Hint: "export PATH=\"$HOME/.sopmod/bin:$PATH\"",
+	}
+}
+
+// checkShims makes sure both shims exist and run this sopmod
+func checkShims(version string) []Check {
+	checks := []Check{}
+	for _, shimPath := range []string{paths.SopShim(), paths.SoplsShim()} {
+		name := filepath.Base(shimPath)
+		if target, err := os.Readlink(shimPath); err == nil {
+			if (!fileExists(shimPath)) {
+				checks = append(checks, Check{
+					Name: "shims",
+					Status: Fail,
+					Message: fmt.Sprintf("%s shim links to %s, which no longer exists", name, target),
+					Hint: "sopmod shim refresh",
+				})
+				continue
+			}
+			checks = append(checks, Check{Name: "shims", Status: Pass, Message: fmt.Sprintf("%s shim links to %s", name, target)})
+			continue
+		}
+
+		if (!fileExists(shimPath)) {
+			checks = append(checks, Check{
+				Name: "shims",
+				Status: Fail,
+				Message: fmt.Sprintf("%s shim is not installed", name),
+				Hint: "sopmod shim refresh",
+			})
+			continue
+		}
+		if shim.IsStale(shimPath, version) {
+			checks = append(checks, Check{
+				Name: "shims",
+				Status: Warn,
+				Message: fmt.Sprintf("%s shim is a copy of a different sopmod", name),
+				Hint: "sopmod shim refresh",
+			})
+			continue
+		}
+		checks = append(checks, Check{Name: "shims", Status: Pass, Message: fmt.Sprintf("%s shim is up to date", name)})
+	}
+	return checks
+}
+
+// checkConfig makes sure config.toml parses, returning what it could load
+func checkConfig(path string) (config.Config, Check) {
+	cfg, err := config.LoadFrom(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config.Config{}, Check{Name: "config", Status: Pass, Message: "no config.toml yet"}
+	}
+	if err != nil {
+		return config.Config{}, Check{
+			Name: "config",
+			Status: Fail,
+			Message: fmt.Sprintf("%s does not parse: %s", path, err),
+			Hint: "fix or delete the file",
+		}
+	}
+	if _, err := cfg.CompatCheckMode(); err != nil {
+		return cfg, Check{Name: "config", Status: Fail, Message: err.Error(), Hint: "fix or remove compat_check"}
+	}
+	return cfg, Check{Name: "config", Status: Pass, Message: fmt.Sprintf("%s parses", path)}
+}
+
+// checkNetwork makes sure the [network] table of config.toml builds a client,
+// and flags turning off certificate verification
+func checkNetwork(cfg config.Config) []Check {
+	if cfg.Network == nil {
+		return nil
+	}
+	_, _err0 := install.NewClient((*cfg.Network))
+	if _err0 != nil {
+		err := _err0
+		return []Check{{Name: "network", Status: Fail, Message: err.Error(), Hint: "fix the [network] table in config.toml"}}
+	}
+	if cfg.Network.InsecureSkipVerify {
+		return []Check{{
+			Name: "network",
+			Status: Warn,
+			Message: "TLS certificate verification is off",
+			Hint: "trust your proxy's certificate with ca_certs instead of insecure_skip_verify",
+		}}
+	}
+	return []Check{{Name: "network", Status: Pass, Message: "proxy and certificate settings load"}}
+}
+
+// checkProject makes sure the nearest version file parses, pins valid versions
+// and, for a sop.mod, has no unknown keys
+func checkProject() Check {
+	path, _err0 := shim.FindProjectFile()
+	if _err0 != nil {
+		err := _err0
+		return Check{Name: "project", Status: Warn, Message: fmt.Sprintf("could not look for sop.mod: %s", err)}
+	}
+	if path == "" {
+		return Check{Name: "project", Status: Pass, Message: "no sop.mod in this directory or its parents"}
+	}
+
+	project, _err1 := config.LoadVersionFile(path)
+	if _err1 != nil {
+		err := _err1
+		return Check{
+			Name: "project",
+			Status: Fail,
+			Message: fmt.Sprintf("%s is invalid: %s", path, err),
+		}
+	}
+	if len(project.Warnings) > 0 {
+		return Check{
+			Name: "project",
+			Status: Warn,
+			Message: fmt.Sprintf("%s: %s", path, strings.Join(project.Warnings, "; ")),
+			Hint: "sopmod reads go, sop and toolchain; check the key names",
+		}
+	}
+	return Check{Name: "project", Status: Pass, Message: fmt.Sprintf("%s parses", path)}
+}
+
+// checkWorkspace makes sure the members of the enclosing workspace, if any,
+// pin versions that work together
+func checkWorkspace(cfg config.Config) []Check {
+	workspace, _err0 := shim.FindWorkspace()
+	if _err0 != nil {
+		err := _err0
+		return []Check{{Name: "workspace", Status: Warn, Message: fmt.Sprintf("could not look for a workspace: %s", err)}}
+	}
+	if workspace == nil {
+		return []Check{}
+	}
+	return checkMembers(cfg, workspace)
+}
+
+// checkMembers fails members whose go doesn't suit their sop, and warns about
+// members pinning a different sop than the root, which is the sop that
+// compiles them when it's run from the root
+func checkMembers(cfg config.Config, workspace *config.ProjectFile) []Check {
+	members, _err0 := workspace.WorkspaceMembers()
+	if _err0 != nil {
+		err := _err0
+		return []Check{{
+			Name: "workspace",
+			Status: Fail,
+			Message: fmt.Sprintf("%s: %s", workspace.Path, err),
+			Hint: "fix the members listed under [workspace]",
+		}}
+	}
+
+	root := filepath.Dir(workspace.Path)
+	rootSop, _, _err1 := workspace.Config.Wanted(cfg)
+	if _err1 != nil {
+		err := _err1
+		return []Check{{Name: "workspace", Status: Fail, Message: fmt.Sprintf("%s: %s", workspace.Path, err)}}
+	}
+
+	checks := []Check{}
+	for _, file := range append([]string{workspace.Path}, members...) {
+		dir := filepath.Dir(file)
+		name, _err2 := filepath.Rel(root, dir)
+		if _err2 != nil {
+			name = dir
+		}
+		pins, _err3 := config.LoadPins(file)
+		if _err3 != nil {
+			err := _err3
+			checks = append(checks, Check{Name: "workspace", Status: Fail, Message: fmt.Sprintf("%s is invalid: %s", file, err)})
+			continue
+		}
+		wantSop, wantGo, _err4 := pins.Wanted(cfg)
+		if _err4 != nil {
+			err := _err4
+			checks = append(checks, Check{Name: "workspace", Status: Fail, Message: fmt.Sprintf("%s: %s", file, err)})
+			continue
+		}
+
+		sopVersion := wantSop
+		if channelVersion, ok := cfg.Channels[wantSop]; ok {
+			sopVersion = channelVersion
+		}
+		if wantSop != "" && wantGo != "" && (!compat.IsGoCompatible(wantGo, sopVersion)) {
+			check := Check{
+				Name: "workspace",
+				Status: Fail,
+				Message: fmt.Sprintf("%s pins go %s, but %s", name, wantGo, compat.CompatMessage(sopVersion)),
+			}
+			if goCompat := compat.GoCompatFor(sopVersion); goCompat != nil {
+				check.Hint = fmt.Sprintf("sopmod pin go %s in %s", goCompat.Min, dir)
+			}
+			checks = append(checks, check)
+		}
+		if file != workspace.Path && rootSop != "" && wantSop != "" && wantSop != rootSop {
+			checks = append(checks, Check{
+				Name: "workspace",
+				Status: Warn,
+				Message: fmt.Sprintf("%s pins sop %s, but sop run from the workspace root is %s", name, wantSop, rootSop),
+				Hint: "pin the same sop in both, or run sop from inside the member",
+			})
+		}
+	}
+
+	if len(checks) == 0 {
+		checks = append(checks, Check{
+			Name: "workspace",
+			Status: Pass,
+			Message: fmt.Sprintf("%s and its %d members pin versions that work together", workspace.Path, len(members)),
+		})
+	}
+	return checks
+}
+
+// checkDefaults makes sure the default versions are installed and work together
+func checkDefaults(cfg config.Config) []Check {
+	installedSop := install.ListInstalledSop()
+	installedGo := install.ListInstalledGo()
+
+	var wantSop, wantGo string
+	source := "default"
+	if cfg.DefaultToolchain != nil {
+		source = "toolchain " + (*cfg.DefaultToolchain)
+		tc, _err0 := cfg.FindToolchain((*cfg.DefaultToolchain))
+		if _err0 != nil {
+			err := _err0
+			return []Check{{Name: "defaults", Status: Fail, Message: err.Error()}}
+		}
+		wantSop = tc.Sop
+		wantGo = tc.Go
+	} else {
+		if cfg.DefaultSop != nil {
+			wantSop = (*cfg.DefaultSop)
+		}
+		if cfg.DefaultGo != nil {
+			wantGo = (*cfg.DefaultGo)
+		}
+	}
+
+	checks := []Check{}
+	sopVersion := shim.ResolveInstalledVersion(wantSop, installedSop)
+	if wantSop == "" {
+		checks = append(checks, Check{Name: "defaults", Status: Warn, Message: "no default sop version", Hint: "sopmod install sop latest"})
+	} else {
+		if sopVersion == "" {
+			checks = append(checks, Check{
+				Name: "defaults",
+				Status: Fail,
+				Message: fmt.Sprintf("%s sop %s is not installed", source, wantSop),
+				Hint: "sopmod install sop " + wantSop,
+			})
+		} else {
+			checks = append(checks, Check{Name: "defaults", Status: Pass, Message: fmt.Sprintf("%s sop %s is installed", source, sopVersion)})
+		}
+	}
+
+	goVersion := shim.ResolveInstalledGo(wantGo, installedGo)
+	if wantGo == "" {
+		checks = append(checks, Check{Name: "defaults", Status: Warn, Message: "no default go version", Hint: "sopmod default <version>"})
+	} else {
+		if goVersion == "" {
+			checks = append(checks, Check{
+				Name: "defaults",
+				Status: Fail,
+				Message: fmt.Sprintf("%s go %s is not installed", source, wantGo),
+				Hint: "sopmod install go " + wantGo,
+			})
+		} else {
+			checks = append(checks, Check{Name: "defaults", Status: Pass, Message: fmt.Sprintf("%s go %s is installed", source, goVersion)})
+		}
+	}
+
+	if sopVersion == "" {
+		return checks
+	}
+
+	// The default go has to work with the default sop, other installs only get a warning
+	if goVersion != "" && (!compat.IsGoCompatible(goVersion, sopVersion)) {
+		checks = append(checks, Check{
+			Name: "compat",
+			Status: Fail,
+			Message: fmt.Sprintf("default go %s is not compatible: %s", goVersion, compat.CompatMessage(sopVersion)),
+			Hint: "sopmod default " + sopVersion,
+		})
+	}
+
+	incompatible := []string{}
+	for _, v := range installedGo {
+		if (!compat.IsGoCompatible(v, sopVersion)) {
+			incompatible = append(incompatible, v)
+		}
+	}
+	if len(incompatible) > 0 {
+		checks = append(checks, Check{
+			Name: "compat",
+			Status: Warn,
+			Message: fmt.Sprintf("go %s can't be used with sop %s: %s", strings.Join(incompatible, ", "), sopVersion, compat.CompatMessage(sopVersion)),
+		})
+	} else {
+		if len(installedGo) > 0 {
+			checks = append(checks, Check{Name: "compat", Status: Pass, Message: fmt.Sprintf("every installed go works with sop %s", sopVersion)})
+		}
+	}
+
+	return checks
+}
+
+// checkInstalls looks for version directories missing their binaries
+func checkInstalls() []Check {
+	checks := []Check{}
+	sopVersions := install.ListInstalledSop()
+	goVersions := install.ListInstalledGo()
+
+	for _, v := range sopVersions {
+		if fileExists(paths.SopBinary(v)) {
+			continue
+		}
+		hint := fmt.Sprintf("sopmod remove sop %s && sopmod install sop %s", v, v)
+		if source := install.SopSource(v); source != "" {
+			hint = fmt.Sprintf("rebuild %s or run sopmod remove sop %s", source, v)
+		}
+		checks = append(checks, Check{
+			Name: "installs",
+			Status: Fail,
+			Message: fmt.Sprintf("sop %s is missing its binary", v),
+			Hint: hint,
+		})
+	}
+
+	for _, v := range goVersions {
+		if fileExists(paths.GoBinary(v)) {
+			continue
+		}
+		checks = append(checks, Check{
+			Name: "installs",
+			Status: Fail,
+			Message: fmt.Sprintf("go %s is missing its binary", v),
+			Hint: fmt.Sprintf("sopmod remove go %s && sopmod install go %s", v, v),
+		})
+	}
+
+	if len(checks) == 0 {
+		checks = append(checks, Check{
+			Name: "installs",
+			Status: Pass,
+			Message: fmt.Sprintf("%d sop and %d go installs are complete", len(sopVersions), len(goVersions)),
+		})
+	}
+	return checks
+}
+
+func exeName(name string) string {
+	if runtime.GOOS == "windows" {
+		return name + ".exe"
+	}
+	return name
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && (!info.IsDir())
+}
+
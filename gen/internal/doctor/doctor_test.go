@@ -0,0 +1,138 @@
+//soppo:generated v1
+package doctor
+
+import "os"
+import "path/filepath"
+import "strings"
+import "testing"
+import "github.com/halcyonnouveau/sopmod/gen/internal/config"
+
+func TestCheckPath(t *testing.T) {
+	root := t.TempDir()
+	binDir := filepath.Join(root, "sopmod", "bin")
+	otherDir := filepath.Join(root, "usr", "bin")
+	emptyDir := filepath.Join(root, "empty")
+	for _, dir := range []string{binDir, otherDir, emptyDir} {
+		_err0 := os.MkdirAll(dir, 0o755)
+		if _err0 != nil {
+			err := _err0
+			t.Fatalf("failed to create dir: %v", err)
+		}
+	}
+	_err1 := os.WriteFile(filepath.Join(otherDir, exeName("sop")), []byte(""), 0o755)
+	if _err1 != nil {
+		err := _err1
+		t.Fatalf("failed to write fake sop: %v", err)
+	}
+
+	join := func(dirs ...string) string {
+		return strings.Join(dirs, string(os.PathListSeparator))
+	}
+
+	tests := []struct {
+		pathVar string
+		want    Status
+	}{
+		{pathVar: join(binDir, otherDir), want: Pass},
+		{pathVar: join(emptyDir, binDir, otherDir), want: Pass},
+		{pathVar: join(otherDir, binDir), want: Fail},
+		{pathVar: join(emptyDir, otherDir), want: Fail},
+		{pathVar: "", want: Fail},
+	}
+
+	for _, tt := range tests {
+		got := checkPath(tt.pathVar, binDir)
+		if got.Status != tt.want {
+			t.Errorf("checkPath(%q) = %s (%s), want %s", tt.pathVar, got.Status, got.Message, tt.want)
+		}
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	_, check := checkConfig(filepath.Join(dir, "missing.toml"))
+	if check.Status != Pass {
+		t.Errorf("missing config: status = %s, want %s", check.Status, Pass)
+	}
+
+	bad := filepath.Join(dir, "bad.toml")
+	_err0 := os.WriteFile(bad, []byte("default_sop = "), 0o644)
+	if _err0 != nil {
+		err := _err0
+		t.Fatalf("failed to write temp file: %v", err)
+	}
+	_, check = checkConfig(bad)
+	if check.Status != Fail {
+		t.Errorf("bad config: status = %s, want %s", check.Status, Fail)
+	}
+
+	good := filepath.Join(dir, "good.toml")
+	_err1 := os.WriteFile(good, []byte("default_sop = \"0.5.0\"\n"), 0o644)
+	if _err1 != nil {
+		err := _err1
+		t.Fatalf("failed to write temp file: %v", err)
+	}
+	cfg, check := checkConfig(good)
+	if check.Status != Pass {
+		t.Errorf("good config: status = %s, want %s", check.Status, Pass)
+	}
+	if cfg.DefaultSop == nil || (*cfg.DefaultSop) != "0.5.0" {
+		t.Errorf("good config: DefaultSop = %v, want 0.5.0", cfg.DefaultSop)
+	}
+	typo := filepath.Join(dir, "typo.toml")
+	_err2 := os.WriteFile(typo, []byte("compat_check = \"strict\"\n"), 0o644)
+	if _err2 != nil {
+		err := _err2
+		t.Fatalf("failed to write temp file: %v", err)
+	}
+	_, check = checkConfig(typo)
+	if check.Status != Fail {
+		t.Errorf("invalid compat_check: status = %s, want %s", check.Status, Fail)
+	}
+}
+
+func TestCheckMembers(t *testing.T) {
+	root := t.TempDir()
+	write := func(name string, content string) {
+		path := filepath.Join(root, name)
+		_err0 := os.MkdirAll(filepath.Dir(path), 0o755)
+		if _err0 != nil {
+			err := _err0
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		_err1 := os.WriteFile(path, []byte(content), 0o644)
+		if _err1 != nil {
+			err := _err1
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	write("sop.mod", "sop = \"0.5\"\ngo = \"1.22\"\n\n[workspace]\nmembers = [\"services/*\", \"tools\"]\n")
+	write("services/api/sop.mod", "sop = \"0.5\"\ngo = \"1.20\"\n")
+	write("services/web/.sop-version", "0.6\n")
+	write("tools/.tool-versions", "sop 0.5\ngolang 1.23\n")
+
+	workspace, _err0 := config.LoadProjectFile(filepath.Join(root, "sop.mod"))
+	if _err0 != nil {
+		err := _err0
+		t.Fatalf("LoadProjectFile failed: %v", err)
+	}
+	checks := checkMembers(config.Config{}, workspace)
+	if len(checks) != 2 {
+		t.Fatalf("checkMembers returned %d checks, want 2: %v", len(checks), checks)
+	}
+	if checks[0].Status != Fail || (!strings.Contains(checks[0].Message, "services/api")) {
+		t.Errorf("first check = %s (%s), want a failure for services/api", checks[0].Status, checks[0].Message)
+	}
+	if checks[1].Status != Warn || (!strings.Contains(checks[1].Message, "services/web")) {
+		t.Errorf("second check = %s (%s), want a warning for services/web", checks[1].Status, checks[1].Message)
+	}
+
+	write("services/api/sop.mod", "sop = \"0.5\"\ngo = \"1.22\"\n")
+	write("services/web/.sop-version", "0.5\n")
+	checks = checkMembers(config.Config{}, workspace)
+	if len(checks) != 1 || checks[0].Status != Pass {
+		t.Errorf("agreeing members: checks = %v, want a single pass", checks)
+	}
+}
+
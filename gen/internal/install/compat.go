@@ -0,0 +1,110 @@
+//soppo:generated v1
+package install
+
+import "crypto/sha256"
+import "encoding/hex"
+import "encoding/json"
+import "fmt"
+import "io"
+import "net/http"
+import "os"
+import "path/filepath"
+import "strings"
+import "github.com/halcyonnouveau/sopmod/gen/internal/compat"
+import "github.com/halcyonnouveau/sopmod/gen/internal/paths"
+import "github.com/halcyonnouveau/sopmod/gen/internal/ui"
+
+// compatAssetName is the Go compatibility manifest soppo releases publish
+const compatAssetName = "compat.toml"
+
+// RefreshCompat fetches the Go compatibility manifest published with the latest
+// soppo release and stores it where the compat package looks for it. A manifest
+// this sopmod can't read never replaces the current one. Returns the release tag.
+func RefreshCompat(verbose bool) (string, error) {
+	req, _err0 := http.NewRequest("GET", "https://api.github.com/repos/halcyonnouveau/soppo/releases/latest", nil)
+	if _err0 != nil {
+		return "", _err0
+	}
+	req.Header.Set("User-Agent", "sopmod")
+
+	resp, _err1 := do(req)
+	if _err1 != nil {
+		return "", _err1
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch the latest soppo release: %s", resp.Status)
+	}
+
+	var release GitHubRelease
+	_err2 := json.NewDecoder(resp.Body).Decode((&release))
+	if _err2 != nil {
+		return "", _err2
+	}
+
+	var asset *GitHubAsset
+	for i := range release.Assets {
+		if release.Assets[i].Name == compatAssetName {
+			asset = (&release.Assets[i])
+			break
+		}
+	}
+	if asset == nil {
+		return "", fmt.Errorf("soppo %s doesn't publish a %s", release.TagName, compatAssetName)
+	}
+
+	if verbose {
+		ui.Progress("Downloading %s from %s", compatAssetName, asset.BrowserDownloadURL)
+	}
+
+	dlReq, _err3 := http.NewRequest("GET", asset.BrowserDownloadURL, nil)
+	if _err3 != nil {
+		return "", _err3
+	}
+	dlReq.Header.Set("User-Agent", "sopmod")
+
+	dlResp, _err4 := do(dlReq)
+	if _err4 != nil {
+		return "", _err4
+	}
+	defer dlResp.Body.Close()
+
+	if dlResp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download %s: %s", compatAssetName, dlResp.Status)
+	}
+
+	data, _err5 := io.ReadAll(io.LimitReader(dlResp.Body, 1 << 20))
+	if _err5 != nil {
+		return "", _err5
+	}
+
+	if wantSum, ok := strings.CutPrefix(asset.Digest, "sha256:"); ok {
+		sum := sha256.Sum256(data)
+		if gotSum := hex.EncodeToString(sum[:]); gotSum != wantSum {
+			return "", fmt.Errorf("checksum mismatch for %s: got %s, want %s", compatAssetName, gotSum, wantSum)
+		}
+	}
+
+	_, _err6 := compat.ParseManifest(string(data))
+	if _err6 != nil {
+		err := _err6
+		return "", fmt.Errorf("soppo %s publishes a %s this sopmod can't read, try `sopmod self update`: %w", release.TagName, compatAssetName, err)
+	}
+
+	path := paths.CompatCacheFile()
+	_err7 := os.MkdirAll(filepath.Dir(path), 0o755)
+	if _err7 != nil {
+		return "", _err7
+	}
+	_err8 := os.WriteFile(path + ".new", data, 0o644)
+	if _err8 != nil {
+		return "", _err8
+	}
+	_err9 := os.Rename(path + ".new", path)
+	if _err9 != nil {
+		return "", _err9
+	}
+	return release.TagName, nil
+}
+
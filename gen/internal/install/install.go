@@ -8,13 +8,17 @@ import "encoding/json"
 import "errors"
 import "fmt"
 import "io"
+import "io/fs"
 import "net/http"
 import "os"
+import "os/exec"
 import "path/filepath"
 import "runtime"
 import "strings"
 import "github.com/halcyonnouveau/sopmod/gen/internal/compat"
+import "github.com/halcyonnouveau/sopmod/gen/internal/config"
 import "github.com/halcyonnouveau/sopmod/gen/internal/paths"
+import "github.com/halcyonnouveau/sopmod/gen/internal/ui"
 
 // Platform holds OS and architecture info for downloads
 type Platform struct {
@@ -63,18 +67,33 @@ type GoRelease struct {
 	Stable bool `json:"stable"`
 }
 
-// ResolveLatestGo resolves "latest" to the actual latest stable Go version
-func ResolveLatestGo() (string, error) {
-	resp, _err0 := http.Get("https://go.dev/dl/?mode=json")
+// fetchGoReleases fetches the Go release list, newest first. Without all,
+// go.dev only returns the two currently supported release lines.
+func fetchGoReleases(all bool) ([]GoRelease, error) {
+	url := "https://go.dev/dl/?mode=json"
+	if all {
+		url += "&include=all"
+	}
+
+	resp, _err0 := get(url)
 	if _err0 != nil {
-		return "", _err0
+		return nil, _err0
 	}
 	defer resp.Body.Close()
 
 	releases := []GoRelease{}
 	_err1 := json.NewDecoder(resp.Body).Decode((&releases))
 	if _err1 != nil {
-		return "", _err1
+		return nil, _err1
+	}
+	return releases, nil
+}
+
+// ResolveLatestGo resolves "latest" to the actual latest stable Go version
+func ResolveLatestGo() (string, error) {
+	releases, _err0 := fetchGoReleases(false)
+	if _err0 != nil {
+		return "", _err0
 	}
 
 	for _, r := range releases {
@@ -86,36 +105,84 @@ func ResolveLatestGo() (string, error) {
 	return "", errors.New("no stable Go version found")
 }
 
-// ResolveGoVersion resolves a Go version, handling "latest" and partial versions
+// ResolveGoVersion resolves a Go version to a release name, handling "latest",
+// partial versions and prereleases.
+//
+// A two-part version like "1.22" is a prefix for the newest stable patch release,
+// while a full version names a single release: "1.20.0" resolves to go1.20, which
+// predates the ".0" naming. Betas and release candidates ("1.23rc1") are only
+// installed when asked for explicitly.
 func ResolveGoVersion(version string) (string, error) {
 	if version == "latest" {
 		return ResolveLatestGo()
 	}
 
-	// Check if it's a partial version like "1.22"
-	parts := strings.Split(version, ".")
-	if len(parts) == 2 {
-		resp, _err0 := http.Get("https://go.dev/dl/?mode=json")
-		if _err0 != nil {
-			return "", _err0
+	wanted, _err0 := compat.ParseGoVersion(version)
+	if _err0 != nil {
+		return "", _err0
+	}
+	if (!wanted.IsPrefix()) {
+		return wanted.String(), nil
+	}
+
+	releases, _err1 := fetchGoReleases(true)
+	if _err1 != nil {
+		return "", _err1
+	}
+
+	var best string
+	var bestVersion compat.GoVersion
+	for _, r := range releases {
+		if (!r.Stable) {
+			continue
+		}
+		v, _err2 := compat.ParseGoVersion(r.Version)
+		if _err2 != nil {
+			continue
 		}
-		defer resp.Body.Close()
+		if v.Major != wanted.Major || v.Minor != wanted.Minor {
+			continue
+		}
+		if best == "" || v.Compare(bestVersion) > 0 {
+			best = v.String()
+			bestVersion = v
+		}
+	}
+
+	if best == "" {
+		return "", fmt.Errorf("version not found: go %s", version)
+	}
+	return best, nil
+}
+
+// ResolveLatestCompatibleGo returns the newest stable Go release that the
+// given sop version works with
+func ResolveLatestCompatibleGo(sopVersion string) (string, error) {
+	releases, _err0 := fetchGoReleases(true)
+	if _err0 != nil {
+		return "", _err0
+	}
 
-		releases := []GoRelease{}
-		_err1 := json.NewDecoder(resp.Body).Decode((&releases))
+	var best string
+	var bestVersion compat.GoVersion
+	for _, r := range releases {
+		if (!r.Stable) || (!compat.IsGoCompatible(r.Version, sopVersion)) {
+			continue
+		}
+		v, _err1 := compat.ParseGoVersion(r.Version)
 		if _err1 != nil {
-			return "", _err1
+			continue
 		}
-
-		prefix := "go" + version
-		for _, r := range releases {
-			if r.Stable && strings.HasPrefix(r.Version, prefix) {
-				return strings.TrimPrefix(r.Version, "go"), nil
-			}
+		if best == "" || v.Compare(bestVersion) > 0 {
+			best = v.String()
+			bestVersion = v
 		}
 	}
 
-	return version, nil
+	if best == "" {
+		return "", fmt.Errorf("no go release works with sop %s", sopVersion)
+	}
+	return best, nil
 }
 
 // InstallGo installs a specific Go version
@@ -124,88 +191,131 @@ func InstallGo(version string, verbose bool) (string, error) {
 	if _err0 != nil {
 		return "", _err0
 	}
-	platform, _err1 := DetectPlatform()
+	_err1 := InstallResolvedGo(resolved, verbose)
 	if _err1 != nil {
 		return "", _err1
 	}
+	return resolved, nil
+}
+
+// InstallResolvedGo installs a go release ResolveGoVersion has already
+// named, without resolving it again: "1.20" here is the go1.20 release, not
+// the newest on the 1.20 line
+func InstallResolvedGo(resolved string, verbose bool) error {
+	platform, _err0 := DetectPlatform()
+	if _err0 != nil {
+		return _err0
+	}
 
 	dest := paths.GoDir(resolved)
+	unlock, _err1 := lockDir(dest)
+	if _err1 != nil {
+		return _err1
+	}
+	defer unlock()
 	if dirExists(dest) {
-		fmt.Printf("\033[32m✓\033[0m go \033[1m%s\033[0m is already installed\n", resolved)
-		return resolved, nil
+		ui.Success("go %s is already installed", ui.Err.Bold(resolved))
+		return nil
+	}
+
+	// Fill a directory beside dest and move it into place once it checks
+	// out, so a failed or killed install never leaves one that looks installed
+	staging, _err2 := stagingDir(dest)
+	if _err2 != nil {
+		return _err2
+	}
+	defer os.RemoveAll(staging)
+	_err3 := fetchGo(resolved, platform, staging, verbose)
+	if _err3 != nil {
+		return _err3
 	}
+	_err4 := os.Rename(staging, dest)
+	if _err4 != nil {
+		return _err4
+	}
+
+	ui.Success("go %s installed successfully", ui.Err.Bold(resolved))
+	return nil
+}
 
+// fetchGo downloads a go release and extracts it into dest
+func fetchGo(resolved string, platform *Platform, dest string, verbose bool) error {
 	ext := platform.GoArchiveExt()
 	filename := fmt.Sprintf("go%s.%s-%s.%s", resolved, platform.OS, platform.Arch, ext)
 	url := "https://go.dev/dl/" + filename
 
 	if verbose {
-		fmt.Printf("Downloading go %s from %s\n", resolved, url)
+		ui.Progress("Downloading go %s from %s", resolved, url)
 	}
 
 	// Download
-	resp, _err2 := http.Get(url)
-	if _err2 != nil {
-		return "", _err2
+	resp, _err0 := get(url)
+	if _err0 != nil {
+		return _err0
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("version not found: go %s for %s-%s", resolved, platform.OS, platform.Arch)
+		return fmt.Errorf("version not found: go %s for %s-%s", resolved, platform.OS, platform.Arch)
 	}
 
 	// Create temp file
-	tmpFile, _err3 := os.CreateTemp("", "go-*." + ext)
-	if _err3 != nil {
-		return "", _err3
+	tmpFile, _err1 := os.CreateTemp("", "go-*." + ext)
+	if _err1 != nil {
+		return _err1
 	}
 	defer os.Remove(tmpFile.Name())
 	defer tmpFile.Close()
 
 	// Download with progress
-	fmt.Printf("Downloading go %s\n", resolved)
-	_, _err4 := io.Copy(tmpFile, resp.Body)
-	if _err4 != nil {
-		return "", _err4
+	ui.Progress("Downloading go %s", resolved)
+	_, _err2 := io.Copy(tmpFile, resp.Body)
+	if _err2 != nil {
+		return _err2
 	}
 	tmpFile.Close()
-	fmt.Println("Download complete")
+	ui.Progress("Downloaded go %s", resolved)
 
 	// Extract
 	if verbose {
-		fmt.Printf("Extracting to %s\n", dest)
+		ui.Progress("Extracting to %s", dest)
 	}
 
-	_err5 := os.MkdirAll(dest, 0o755)
-	if _err5 != nil {
-		return "", _err5
+	_err3 := os.MkdirAll(dest, 0o755)
+	if _err3 != nil {
+		return _err3
 	}
 
 	if ext == "zip" {
-		_err6 := extractZip(tmpFile.Name(), dest)
-		if _err6 != nil {
-			return "", _err6
+		_err4 := extractZip(tmpFile.Name(), dest)
+		if _err4 != nil {
+			return _err4
 		}
 	} else {
-		_err7 := extractTarGz(tmpFile.Name(), dest)
-		if _err7 != nil {
-			return "", _err7
+		_err5 := extractTarGz(tmpFile.Name(), dest)
+		if _err5 != nil {
+			return _err5
 		}
 	}
 
-	// Verify
-	goBin := paths.GoBinary(resolved)
+	// Verify the binary runs and is the release that was asked for
+	goBin := filepath.Join(dest, "go", "bin", exeName("go"))
 	if (!fileExists(goBin)) {
-		return "", fmt.Errorf("go binary not found at %s", goBin)
+		return fmt.Errorf("go binary not found at %s", goBin)
 	}
-
-	fmt.Printf("\033[32m✓\033[0m go \033[1m%s\033[0m installed successfully\n", resolved)
-	return resolved, nil
+	_err6 := verifyGo(goBin, resolved)
+	if _err6 != nil {
+		err := _err6
+		return fmt.Errorf("go %s failed its check after download and was not installed: %w", resolved, err)
+	}
+	return nil
 }
 
 // GitHubRelease represents a GitHub release
 type GitHubRelease struct {
 	TagName string `json:"tag_name"`
+	Prerelease bool `json:"prerelease"`
+	Draft bool `json:"draft"`
 	Assets []GitHubAsset `json:"assets"`
 }
 
@@ -213,8 +323,11 @@ type GitHubRelease struct {
 type GitHubAsset struct {
 	Name string `json:"name"`
 	BrowserDownloadURL string `json:"browser_download_url"`
+	Digest string `json:"digest"`
 }
 
+// "sha256:<hex>", empty for assets uploaded before GitHub published digests
+
 // ResolveLatestSop resolves "latest" to the actual latest sop version
 func ResolveLatestSop() (string, error) {
 	req, _err0 := http.NewRequest("GET", "https://api.github.com/repos/halcyonnouveau/soppo/releases/latest", nil)
@@ -223,7 +336,7 @@ func ResolveLatestSop() (string, error) {
 	}
 	req.Header.Set("User-Agent", "sopmod")
 
-	resp, _err1 := http.DefaultClient.Do(req)
+	resp, _err1 := do(req)
 	if _err1 != nil {
 		return "", _err1
 	}
@@ -238,29 +351,116 @@ func ResolveLatestSop() (string, error) {
 	return strings.TrimPrefix(release.TagName, "v"), nil
 }
 
-// ResolveSopVersion resolves a sop version, handling "latest"
+// SopChannels lists the release channels sop can be installed from.
+// stable follows GitHub's latest release, beta also includes prereleases,
+// and nightly follows releases tagged "nightly-<date>".
+var SopChannels = []string{"stable", "beta", "nightly"}
+
+// IsSopChannel reports whether name is a release channel rather than a version
+func IsSopChannel(name string) bool {
+	for _, c := range SopChannels {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveSopChannel resolves a release channel to the newest sop version published on it
+func ResolveSopChannel(channel string) (string, error) {
+	switch channel {
+	case "stable":
+		return ResolveLatestSop()
+	case "beta", "nightly":
+	default:
+		return "", fmt.Errorf("unknown channel '%s'. Use %s", channel, strings.Join(SopChannels, ", "))
+	}
+
+	req, _err0 := http.NewRequest("GET", "https://api.github.com/repos/halcyonnouveau/soppo/releases?per_page=50", nil)
+	if _err0 != nil {
+		return "", _err0
+	}
+	req.Header.Set("User-Agent", "sopmod")
+
+	resp, _err1 := do(req)
+	if _err1 != nil {
+		return "", _err1
+	}
+	defer resp.Body.Close()
+
+	releases := []GitHubRelease{}
+	_err2 := json.NewDecoder(resp.Body).Decode((&releases))
+	if _err2 != nil {
+		return "", _err2
+	}
+
+	// Releases are listed newest first
+	for _, r := range releases {
+		if r.Draft {
+			continue
+		}
+		nightly := isNightly(r.TagName)
+		if (channel == "nightly") == nightly {
+			return strings.TrimPrefix(r.TagName, "v"), nil
+		}
+	}
+
+	return "", fmt.Errorf("no sop release found on the %s channel", channel)
+}
+
+// ResolveSopVersion resolves a sop version, handling "latest" and channel names
 func ResolveSopVersion(version string) (string, error) {
 	if version == "latest" {
 		return ResolveLatestSop()
 	}
+	if IsSopChannel(version) {
+		return ResolveSopChannel(version)
+	}
 	return version, nil
 }
 
+func isNightly(version string) bool {
+	return strings.HasPrefix(version, "nightly")
+}
+
+// sopTag returns the release tag for a sop version. Nightly tags carry no "v".
+func sopTag(version string) string {
+	if isNightly(version) {
+		return version
+	}
+	return "v" + version
+}
+
 // InstallSop installs a specific sop version
 func InstallSop(version string, verbose bool) (string, error) {
 	resolved, _err0 := ResolveSopVersion(version)
 	if _err0 != nil {
 		return "", _err0
 	}
-	platform, _err1 := DetectPlatform()
+	_err1 := InstallResolvedSop(resolved, verbose)
 	if _err1 != nil {
 		return "", _err1
 	}
+	return resolved, nil
+}
+
+// InstallResolvedSop installs a sop release ResolveSopVersion has already
+// named, without resolving it again
+func InstallResolvedSop(resolved string, verbose bool) error {
+	platform, _err0 := DetectPlatform()
+	if _err0 != nil {
+		return _err0
+	}
 
 	dest := paths.SopDir(resolved)
+	unlock, _err1 := lockDir(dest)
+	if _err1 != nil {
+		return _err1
+	}
+	defer unlock()
 	if dirExists(dest) {
-		fmt.Printf("\033[32m✓\033[0m sop \033[1m%s\033[0m is already installed\n", resolved)
-		return resolved, nil
+		ui.Success("sop %s is already installed", ui.Err.Bold(resolved))
+		return nil
 	}
 
 	// Check Go compatibility
@@ -275,12 +475,34 @@ func InstallSop(version string, verbose bool) (string, error) {
 			}
 		}
 		if (!hasCompatible) && len(installedGo) > 0 {
-			fmt.Printf("\033[33mwarning:\033[0m %s\n", compat.CompatMessage(resolved))
-			fmt.Printf("  Installed go versions: %s\n", strings.Join(installedGo, ", "))
-			fmt.Printf("  \033[36mhint:\033[0m run \033[1msopmod install go %s\033[0m\n", compatInfo.Min)
+			ui.Warn("%s", compat.CompatMessage(resolved))
+			ui.Progress("  Installed go versions: %s", strings.Join(installedGo, ", "))
+			ui.Hint("run %s", ui.Err.Bold("sopmod install go " + compatInfo.Min))
 		}
 	}
 
+	// Fill a directory beside dest and move it into place once it checks
+	// out, so a failed or killed install never leaves one that looks installed
+	staging, _err2 := stagingDir(dest)
+	if _err2 != nil {
+		return _err2
+	}
+	defer os.RemoveAll(staging)
+	_err3 := fetchSop(resolved, platform, staging, verbose)
+	if _err3 != nil {
+		return _err3
+	}
+	_err4 := os.Rename(staging, dest)
+	if _err4 != nil {
+		return _err4
+	}
+
+	ui.Success("sop %s installed successfully", ui.Err.Bold(resolved))
+	return nil
+}
+
+// fetchSop downloads a sop release's sop and sopls binaries into dest, both at once
+func fetchSop(resolved string, platform *Platform, dest string, verbose bool) error {
 	// Map platform to Rust target triple
 	var targetTriple string
 	switch platform.OS + "-" + platform.Arch {
@@ -297,33 +519,33 @@ func InstallSop(version string, verbose bool) (string, error) {
 	case "windows-arm64":
 		targetTriple = "aarch64-pc-windows-msvc"
 	default:
-		return "", fmt.Errorf("unsupported platform: %s-%s", platform.OS, platform.Arch)
+		return fmt.Errorf("unsupported platform: %s-%s", platform.OS, platform.Arch)
 	}
 
 	// Fetch release info
-	tag := "v" + resolved
+	tag := sopTag(resolved)
 	releaseURL := fmt.Sprintf("https://api.github.com/repos/halcyonnouveau/soppo/releases/tags/%s", tag)
 
-	req, _err2 := http.NewRequest("GET", releaseURL, nil)
-	if _err2 != nil {
-		return "", _err2
+	req, _err0 := http.NewRequest("GET", releaseURL, nil)
+	if _err0 != nil {
+		return _err0
 	}
 	req.Header.Set("User-Agent", "sopmod")
 
-	resp, _err3 := http.DefaultClient.Do(req)
-	if _err3 != nil {
-		return "", _err3
+	resp, _err1 := do(req)
+	if _err1 != nil {
+		return _err1
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("version not found: sop %s", resolved)
+		return fmt.Errorf("version not found: sop %s", resolved)
 	}
 
 	var release GitHubRelease
-	_err4 := json.NewDecoder(resp.Body).Decode((&release))
-	if _err4 != nil {
-		return "", _err4
+	_err2 := json.NewDecoder(resp.Body).Decode((&release))
+	if _err2 != nil {
+		return _err2
 	}
 
 	// Find the sop and sopls assets
@@ -340,36 +562,239 @@ func InstallSop(version string, verbose bool) (string, error) {
 		}
 	}
 	if sopAsset == nil {
-		return "", fmt.Errorf("version not found: sop %s for %s", resolved, targetTriple)
+		return fmt.Errorf("version not found: sop %s for %s", resolved, targetTriple)
+	}
+
+	_err3 := os.MkdirAll(dest, 0o755)
+	if _err3 != nil {
+		return _err3
 	}
 
-	_err5 := os.MkdirAll(dest, 0o755)
+	// Download sop, and sopls if the release has it, side by side
+	jobs := []Job{{Name: "sop", Run: func() error {
+		return downloadBinary(sopAsset, dest, "sop", resolved, verbose)
+	}}}
+	if soplsAsset != nil {
+		jobs = append(jobs, Job{Name: "sopls", Run: func() error {
+			return downloadBinary(soplsAsset, dest, "sopls", resolved, verbose)
+		}})
+	}
+	_err4 := RunJobs(jobs, Workers)
+	if _err4 != nil {
+		return _err4
+	}
+
+	// Verify sop runs and is the release that was asked for
+	sopBin := filepath.Join(dest, exeName("sop"))
+	if (!fileExists(sopBin)) {
+		return fmt.Errorf("sop binary not found at %s", sopBin)
+	}
+	_err5 := verifySop(sopBin, resolved)
 	if _err5 != nil {
-		return "", _err5
+		err := _err5
+		return fmt.Errorf("sop %s failed its check after download and was not installed: %w", resolved, err)
+	}
+	return nil
+}
+
+// LinkSop registers an existing local sop build as a named toolchain.
+// The binaries are symlinked so rebuilding the checkout updates the toolchain,
+// falling back to copies where symlinks aren't available.
+func LinkSop(name string, dir string) error {
+	_err0 := validateToolchainName(name)
+	if _err0 != nil {
+		return _err0
+	}
+	src, _err1 := filepath.Abs(dir)
+	if _err1 != nil {
+		return _err1
 	}
 
-	// Download sop
-	_err6 := downloadBinary(sopAsset, dest, "sop", verbose)
+	if (!fileExists(filepath.Join(src, exeName("sop")))) {
+		return fmt.Errorf("no %s binary found in %s", exeName("sop"), src)
+	}
+
+	dest := paths.SopDir(name)
+	if dirExists(dest) {
+		return fmt.Errorf("sop %s already exists. Run `sopmod remove sop %s` first", name, name)
+	}
+	_err2 := os.MkdirAll(dest, 0o755)
+	if _err2 != nil {
+		return _err2
+	}
+
+	for _, bin := range []string{"sop", "sopls"} {
+		binPath := filepath.Join(src, exeName(bin))
+		if (!fileExists(binPath)) {
+			continue
+		}
+		_err3 := linkOrCopy(binPath, filepath.Join(dest, exeName(bin)))
+		if _err3 != nil {
+			err := _err3
+			os.RemoveAll(dest)
+			return err
+		}
+	}
+
+	_err4 := os.WriteFile(paths.SopSourceFile(name), []byte(src + "\n"), 0o644)
+	if _err4 != nil {
+		return _err4
+	}
+
+	ui.Success("Linked sop %s to %s", ui.Err.Bold(name), src)
+	return nil
+}
+
+// InstallSopFromGit builds sop from a git ref of the soppo repository and
+// installs it as a named toolchain. Needs git and cargo on PATH.
+func InstallSopFromGit(name string, ref string, verbose bool) error {
+	_err0 := validateToolchainName(name)
+	if _err0 != nil {
+		return _err0
+	}
+
+	dest := paths.SopDir(name)
+	unlock, _err1 := lockDir(dest)
+	if _err1 != nil {
+		return _err1
+	}
+	defer unlock()
+	if dirExists(dest) {
+		return fmt.Errorf("sop %s already exists. Run `sopmod remove sop %s` first", name, name)
+	}
+
+	for _, tool := range []string{"git", "cargo"} {
+		_, _err2 := exec.LookPath(tool)
+		if _err2 != nil {
+			return fmt.Errorf("building sop from git requires %s on PATH", tool)
+		}
+	}
+
+	srcDir, _err3 := os.MkdirTemp("", "sopmod-git-*")
+	if _err3 != nil {
+		return _err3
+	}
+	defer os.RemoveAll(srcDir)
+
+	// git and cargo make their own requests, so they get the [network] settings too
+	caBundle := srcDir + "-ca.pem"
+	defer os.Remove(caBundle)
+	env, _err4 := commandEnv(config.Load(), caBundle)
+	if _err4 != nil {
+		return _err4
+	}
+
+	// Fetching a single ref works for branches, tags and commit hashes alike
+	ui.Progress("Fetching soppo %s", ref)
+	_err5 := runCommand(srcDir, env, verbose, "git", "init", "--quiet")
+	if _err5 != nil {
+		return _err5
+	}
+	_err6 := runCommand(srcDir, env, verbose, "git", "fetch", "--depth", "1", soppoRepoURL, ref)
 	if _err6 != nil {
-		return "", _err6
+		return _err6
+	}
+	_err7 := runCommand(srcDir, env, verbose, "git", "checkout", "--quiet", "FETCH_HEAD")
+	if _err7 != nil {
+		return _err7
 	}
 
-	// Download sopls if available
-	if soplsAsset != nil {
-		_err7 := downloadBinary(soplsAsset, dest, "sopls", verbose)
-		if _err7 != nil {
-			return "", _err7
+	ui.Progress("Building sop %s", ref)
+	_err8 := runCommand(srcDir, env, verbose, "cargo", "build", "--release")
+	if _err8 != nil {
+		return _err8
+	}
+
+	_err9 := os.MkdirAll(dest, 0o755)
+	if _err9 != nil {
+		return _err9
+	}
+	buildDir := filepath.Join(srcDir, "target", "release")
+	for _, bin := range []string{"sop", "sopls"} {
+		binPath := filepath.Join(buildDir, exeName(bin))
+		if (!fileExists(binPath)) {
+			continue
 		}
+		destPath := filepath.Join(dest, exeName(bin))
+		_err10 := copyFile(binPath, destPath)
+		if _err10 != nil {
+			err := _err10
+			os.RemoveAll(dest)
+			return err
+		}
+		os.Chmod(destPath, 0o755)
 	}
 
-	// Verify sop
-	sopBin := paths.SopBinary(resolved)
-	if (!fileExists(sopBin)) {
-		return "", fmt.Errorf("sop binary not found at %s", sopBin)
+	if (!fileExists(paths.SopBinary(name))) {
+		os.RemoveAll(dest)
+		return fmt.Errorf("build did not produce %s", exeName("sop"))
 	}
 
-	fmt.Printf("\033[32m✓\033[0m sop \033[1m%s\033[0m installed successfully\n", resolved)
-	return resolved, nil
+	_err11 := os.WriteFile(paths.SopSourceFile(name), []byte("git " + ref + "\n"), 0o644)
+	if _err11 != nil {
+		return _err11
+	}
+
+	ui.Success("sop %s built from %s", ui.Err.Bold(name), ref)
+	return nil
+}
+
+// SopSource returns where a custom sop toolchain came from: a local directory
+// for linked builds or "git <ref>" for git builds. Empty for released versions.
+func SopSource(version string) string {
+	data, _err0 := os.ReadFile(paths.SopSourceFile(version))
+	if _err0 != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+const soppoRepoURL = "https://github.com/halcyonnouveau/soppo.git"
+
+// validateToolchainName rejects names that would be mistaken for releases or channels
+func validateToolchainName(name string) error {
+	if name == "" || name == "latest" || IsSopChannel(name) || isNightly(name) {
+		return fmt.Errorf("'%s' is reserved and can't be used as a toolchain name", name)
+	}
+	if strings.ContainsAny(name, "/\\") || name[0] < 'a' || name[0] > 'z' {
+		return fmt.Errorf("invalid toolchain name '%s': use a name starting with a lowercase letter, like dev", name)
+	}
+	return nil
+}
+
+func runCommand(dir string, env []string, verbose bool, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	if verbose {
+		cmd.Stdout = ui.Err
+		cmd.Stderr = ui.Err
+		return cmd.Run()
+	}
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %s\n%s", name, strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func linkOrCopy(src string, dst string) error {
+	if os.Symlink(src, dst) == nil {
+		return nil
+	}
+	_err0 := copyFile(src, dst)
+	if _err0 != nil {
+		return _err0
+	}
+	return os.Chmod(dst, 0o755)
+}
+
+func exeName(name string) string {
+	if runtime.GOOS == "windows" {
+		return name + ".exe"
+	}
+	return name
 }
 
 // ListInstalledGo returns a list of installed Go versions
@@ -386,7 +811,7 @@ func ListInstalledGo() []string {
 
 	versions := []string{}
 	for _, e := range entries {
-		if e.IsDir() {
+		if e.IsDir() && (!isStaging(e.Name())) {
 			versions = append(versions, e.Name())
 		}
 	}
@@ -407,7 +832,7 @@ func ListInstalledSop() []string {
 
 	versions := []string{}
 	for _, e := range entries {
-		if e.IsDir() {
+		if e.IsDir() && (!isStaging(e.Name())) {
 			versions = append(versions, e.Name())
 		}
 	}
@@ -417,31 +842,60 @@ func ListInstalledSop() []string {
 // RemoveGo removes an installed Go version
 func RemoveGo(version string) error {
 	dir := paths.GoDir(version)
+	unlock, _err0 := lockDir(dir)
+	if _err0 != nil {
+		return _err0
+	}
+	defer unlock()
 	if (!dirExists(dir)) {
 		return fmt.Errorf("version not found: go %s", version)
 	}
-	_err0 := os.RemoveAll(dir)
-	if _err0 != nil {
-		return _err0
+	_err1 := os.RemoveAll(dir)
+	if _err1 != nil {
+		return _err1
 	}
-	fmt.Printf("\033[32m✓\033[0m Removed go \033[1m%s\033[0m\n", version)
+	ui.Success("Removed go %s", ui.Err.Bold(version))
 	return nil
 }
 
 // RemoveSop removes an installed sop version
 func RemoveSop(version string) error {
 	dir := paths.SopDir(version)
+	unlock, _err0 := lockDir(dir)
+	if _err0 != nil {
+		return _err0
+	}
+	defer unlock()
 	if (!dirExists(dir)) {
 		return fmt.Errorf("version not found: sop %s", version)
 	}
-	_err0 := os.RemoveAll(dir)
-	if _err0 != nil {
-		return _err0
+	_err1 := os.RemoveAll(dir)
+	if _err1 != nil {
+		return _err1
 	}
-	fmt.Printf("\033[32m✓\033[0m Removed sop \033[1m%s\033[0m\n", version)
+	ui.Success("Removed sop %s", ui.Err.Bold(version))
 	return nil
 }
 
+// DirSize returns the total size of the files under path, without following symlinks
+func DirSize(path string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, _err0 := d.Info()
+			if _err0 != nil {
+				return _err0
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
 func extractTarGz(archivePath string, dest string) error {
 	f, _err0 := os.Open(archivePath)
 	if _err0 != nil {
@@ -555,9 +1009,9 @@ func copyFile(src string, dst string) error {
 	return err
 }
 
-func downloadBinary(asset *GitHubAsset, dest string, name string, verbose bool) error {
+func downloadBinary(asset *GitHubAsset, dest string, name string, version string, verbose bool) error {
 	if verbose {
-		fmt.Printf("Downloading %s from %s\n", name, asset.BrowserDownloadURL)
+		ui.Progress("Downloading %s %s from %s", name, version, asset.BrowserDownloadURL)
 	}
 
 	req, _err0 := http.NewRequest("GET", asset.BrowserDownloadURL, nil)
@@ -566,7 +1020,7 @@ func downloadBinary(asset *GitHubAsset, dest string, name string, verbose bool)
 	}
 	req.Header.Set("User-Agent", "sopmod")
 
-	resp, _err1 := http.DefaultClient.Do(req)
+	resp, _err1 := do(req)
 	if _err1 != nil {
 		return _err1
 	}
@@ -584,7 +1038,7 @@ func downloadBinary(asset *GitHubAsset, dest string, name string, verbose bool)
 	defer os.Remove(tmpFile.Name())
 	defer tmpFile.Close()
 
-	fmt.Printf("Downloading %s\n", name)
+	ui.Progress("Downloading %s %s", name, version)
 	_, _err3 := io.Copy(tmpFile, resp.Body)
 	if _err3 != nil {
 		return _err3
@@ -605,11 +1059,7 @@ func downloadBinary(asset *GitHubAsset, dest string, name string, verbose bool)
 			}
 		} else {
 			// Raw binary
-			binaryName := name
-			if runtime.GOOS == "windows" {
-				binaryName = name + ".exe"
-			}
-			destPath := filepath.Join(dest, binaryName)
+			destPath := filepath.Join(dest, exeName(name))
 			_err6 := copyFile(tmpFile.Name(), destPath)
 			if _err6 != nil {
 				return _err6
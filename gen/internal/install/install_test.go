@@ -0,0 +1,532 @@
+//soppo:generated v1
+package install
+
+import "encoding/base64"
+import "encoding/pem"
+import "errors"
+import "io"
+import "net/http"
+import "net/http/httptest"
+import "os"
+import "path/filepath"
+import "runtime"
+import "slices"
+import "strings"
+import "sync"
+import "sync/atomic"
+import "testing"
+import "time"
+import "github.com/halcyonnouveau/sopmod/gen/internal/config"
+
+func TestRunJobs(t *testing.T) {
+	var running, peak, ran atomic.Int32
+	job := func(name string, fail bool) Job {
+		return Job{Name: name, Run: func() error {
+			now := running.Add(1)
+			defer running.Add(-1)
+			for {
+				seen := peak.Load()
+				if now <= seen || peak.CompareAndSwap(seen, now) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			ran.Add(1)
+			if fail {
+				return errors.New("download failed")
+			}
+			return nil
+		}}
+	}
+
+	jobs := []Job{job("go 1.23.4", false), job("sop 0.5.1", true), job("sopls 0.5.1", false), job("go 1.22.8", true), job("sop 0.6.0", false)}
+	err := RunJobs(jobs, 2)
+	if ran.Load() != 5 {
+		t.Errorf("ran %d jobs, want all 5 despite failures", ran.Load())
+	}
+	if peak.Load() > 2 {
+		t.Errorf("%d jobs ran at once, want at most 2", peak.Load())
+	}
+	if err == nil {
+		t.Fatal("RunJobs should return the failures")
+	}
+	want := "sop 0.5.1: download failed\ngo 1.22.8: download failed"
+	if err.Error() != want {
+		t.Errorf("RunJobs error = %q, want %q", err.Error(), want)
+	}
+
+	if err := RunJobs(nil, Workers); err != nil {
+		t.Errorf("RunJobs(nil) = %v, want nil", err)
+	}
+}
+
+func TestLockDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "go", "1.23.4")
+	unlock, _err0 := lockDir(dir)
+	if _err0 != nil {
+		err := _err0
+		t.Fatalf("lockDir failed: %v", err)
+	}
+
+	acquired := make(chan func())
+	go func() {
+		second, err := lockDir(dir)
+		if err != nil {
+			t.Errorf("second lockDir failed: %v", err)
+			close(acquired)
+			return
+		}
+		acquired <- second
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second lockDir got the lock while it was held")
+	case <-time.After(2 * lockPollInterval):
+	}
+	unlock()
+
+	select {
+	case second := <-acquired:
+		if second != nil {
+			second()
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("second lockDir never got the lock after it was released")
+	}
+	if _, err := os.Stat(dir + ".lock"); err == nil {
+		t.Error("lock file is still there after both released it")
+	}
+
+	// A lock nobody has touched in a while was left by a sopmod that died
+	_err1 := os.WriteFile(dir + ".lock", []byte("12345\n"), 0o644)
+	if _err1 != nil {
+		err := _err1
+		t.Fatalf("failed to write lock file: %v", err)
+	}
+	old := time.Now().Add(-2 * staleLockAge)
+	_err2 := os.Chtimes(dir + ".lock", old, old)
+	if _err2 != nil {
+		err := _err2
+		t.Fatalf("failed to age lock file: %v", err)
+	}
+	var _err3 error
+	unlock, _err3 = lockDir(dir)
+	if _err3 != nil {
+		err := _err3
+		t.Fatalf("lockDir over a stale lock failed: %v", err)
+	}
+	data, _err4 := os.ReadFile(dir + ".lock")
+	if _err4 != nil {
+		err := _err4
+		t.Fatalf("failed to read lock file: %v", err)
+	}
+	if strings.TrimSpace(string(data)) == "12345" {
+		t.Error("stale lock was not replaced")
+	}
+	unlock()
+}
+
+func TestStagingDir(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "1.23.4")
+	leftover := dest + stagingMarker + "123"
+	_err0 := os.MkdirAll(filepath.Join(leftover, "go"), 0o755)
+	if _err0 != nil {
+		err := _err0
+		t.Fatalf("failed to create leftover: %v", err)
+	}
+
+	staging, _err1 := stagingDir(dest)
+	if _err1 != nil {
+		err := _err1
+		t.Fatalf("stagingDir failed: %v", err)
+	}
+	if (!isStaging(filepath.Base(staging))) {
+		t.Errorf("isStaging(%q) = false, want true", filepath.Base(staging))
+	}
+	if filepath.Dir(staging) != filepath.Dir(dest) {
+		t.Errorf("staging dir %s is not beside %s", staging, dest)
+	}
+	if _, err := os.Stat(leftover); err == nil {
+		t.Error("leftover from an interrupted install was not removed")
+	}
+	if isStaging(filepath.Base(dest)) {
+		t.Errorf("isStaging(%q) = true, want false", filepath.Base(dest))
+	}
+}
+
+func TestBypassProxy(t *testing.T) {
+	noProxy := []string{"localhost", ".corp.example", "*.internal", "10.0.0.0/8", " Mirror.Example.com "}
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{host: "localhost", want: true},
+		{host: "corp.example", want: true},
+		{host: "git.corp.example", want: true},
+		{host: "notcorp.example", want: false},
+		{host: "build.internal", want: true},
+		{host: "10.1.2.3", want: true},
+		{host: "192.168.1.1", want: false},
+		{host: "MIRROR.example.com", want: true},
+		{host: "go.dev", want: false},
+	}
+	for _, tt := range tests {
+		if got := bypassProxy(tt.host, noProxy); got != tt.want {
+			t.Errorf("bypassProxy(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+	if (!bypassProxy("go.dev", []string{"*"})) {
+		t.Error("no_proxy \"*\" should bypass the proxy for every host")
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "direct")
+	}))
+	defer server.Close()
+
+	// The test server's certificate is only trusted once ca_certs names it
+	plain, _err0 := NewClient(config.Network{})
+	if _err0 != nil {
+		err := _err0
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if resp, err := plain.Get(server.URL); err == nil {
+		resp.Body.Close()
+		t.Error("request to a server signed by an unknown CA should fail")
+	}
+
+	certFile := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory((&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	_err1 := os.WriteFile(certFile, cert, 0o644)
+	if _err1 != nil {
+		err := _err1
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+	trusted, _err2 := NewClient(config.Network{CACerts: []string{certFile}})
+	if _err2 != nil {
+		err := _err2
+		t.Fatalf("NewClient with ca_certs failed: %v", err)
+	}
+	if got := fetchBody(t, trusted, server.URL); got != "direct" {
+		t.Errorf("trusted client got %q, want %q", got, "direct")
+	}
+
+	insecure, _err3 := NewClient(config.Network{InsecureSkipVerify: true})
+	if _err3 != nil {
+		err := _err3
+		t.Fatalf("NewClient with insecure_skip_verify failed: %v", err)
+	}
+	if got := fetchBody(t, insecure, server.URL); got != "direct" {
+		t.Errorf("insecure client got %q, want %q", got, "direct")
+	}
+
+	if _, err := NewClient(config.Network{CACerts: []string{filepath.Join(t.TempDir(), "missing.pem")}}); err == nil {
+		t.Error("a missing ca_certs file should fail")
+	}
+	notPEM := filepath.Join(t.TempDir(), "ca.txt")
+	_err4 := os.WriteFile(notPEM, []byte("not a certificate\n"), 0o644)
+	if _err4 != nil {
+		err := _err4
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if _, err := NewClient(config.Network{CACerts: []string{notPEM}}); err == nil {
+		t.Error("a ca_certs file with no certificates should fail")
+	}
+}
+
+func TestNewClientProxy(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "direct")
+	}))
+	defer target.Close()
+
+	var auth atomic.Value
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth.Store(r.Header.Get("Proxy-Authorization"))
+		io.WriteString(w, "proxied")
+	}))
+	defer proxy.Close()
+
+	// The password comes from the environment rather than config.toml
+	t.Setenv("SOPMOD_TEST_PROXY_PASSWORD", "hunter2")
+	proxyURL := strings.Replace(proxy.URL, "http://", "http://sopmod:$SOPMOD_TEST_PROXY_PASSWORD@", 1)
+
+	client, _err0 := NewClient(config.Network{Proxy: proxyURL})
+	if _err0 != nil {
+		err := _err0
+		t.Fatalf("NewClient with proxy failed: %v", err)
+	}
+	if got := fetchBody(t, client, target.URL); got != "proxied" {
+		t.Errorf("request went %q, want through the proxy", got)
+	}
+	wantAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte("sopmod:hunter2"))
+	if got, _ := auth.Load().(string); got != wantAuth {
+		t.Errorf("Proxy-Authorization = %q, want %q", got, wantAuth)
+	}
+
+	bypass, _err1 := NewClient(config.Network{Proxy: proxyURL, NoProxy: []string{"127.0.0.0/8"}})
+	if _err1 != nil {
+		err := _err1
+		t.Fatalf("NewClient with no_proxy failed: %v", err)
+	}
+	if got := fetchBody(t, bypass, target.URL); got != "direct" {
+		t.Errorf("request went %q, want direct for a no_proxy host", got)
+	}
+
+	for _, bad := range []string{"proxy.example.com:3128", "ftp://proxy.example.com"} {
+		if _, err := NewClient(config.Network{Proxy: bad}); err == nil {
+			t.Errorf("NewClient(proxy = %q) should fail", bad)
+		}
+	}
+}
+
+func fetchBody(t *testing.T, client *http.Client, url string) string {
+	t.Helper()
+	resp, _err0 := client.Get(url)
+	if _err0 != nil {
+		err := _err0
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, _err1 := io.ReadAll(resp.Body)
+	if _err1 != nil {
+		err := _err1
+		t.Fatalf("failed to read %s: %v", url, err)
+	}
+	return string(body)
+}
+
+func TestReportsVersion(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+		ok     bool
+	}{
+		{output: "sop 0.5.1", want: "0.5.1", ok: true},
+		{output: "sop v0.5.1 (linux x86_64)", want: "0.5.1", ok: true},
+		{output: "sop 0.5.10", want: "0.5.1", ok: false},
+		{output: "go version go1.23.4 linux/amd64", want: "go1.23.4", ok: true},
+		{output: "go version go1.23.4 linux/amd64", want: "go1.23", ok: false},
+		{output: "go version go1.24rc1 darwin/arm64", want: "go1.24rc1", ok: true},
+		{output: "", want: "0.5.1", ok: false},
+	}
+	for _, tt := range tests {
+		if got := reportsVersion(tt.output, tt.want); got != tt.ok {
+			t.Errorf("reportsVersion(%q, %q) = %v, want %v", tt.output, tt.want, got, tt.ok)
+		}
+	}
+}
+
+func TestVerifyBinaries(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs /bin/sh")
+	}
+	dir := t.TempDir()
+	script := func(name string, body string) string {
+		path := filepath.Join(dir, name)
+		_err0 := os.WriteFile(path, []byte("#!/bin/sh\n" + body + "\n"), 0o755)
+		if _err0 != nil {
+			err := _err0
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		return path
+	}
+
+	sop := script("sop", "echo sop 0.5.1")
+	if err := verifySop(sop, "0.5.1"); err != nil {
+		t.Errorf("verifySop on a matching sop failed: %v", err)
+	}
+	if err := verifySop(sop, "0.6.0"); err == nil {
+		t.Error("verifySop should fail when sop reports another version")
+	}
+	if err := verifySop(sop, "nightly-2026-01-02"); err != nil {
+		t.Errorf("verifySop on a nightly that runs failed: %v", err)
+	}
+
+	goBin := script("go", "test \"$GOTOOLCHAIN\" = local || exit 1\necho go version go1.23.4 linux/amd64")
+	if err := verifyGo(goBin, "1.23.4"); err != nil {
+		t.Errorf("verifyGo on a matching go failed: %v", err)
+	}
+	if err := verifyGo(goBin, "1.22.8"); err == nil {
+		t.Error("verifyGo should fail when go reports another version")
+	}
+
+	// A cut-off download doesn't run at all
+	truncated := filepath.Join(dir, "truncated")
+	_err1 := os.WriteFile(truncated, []byte{0x7f, 'E', 'L', 'F', 2, 1}, 0o755)
+	if _err1 != nil {
+		err := _err1
+		t.Fatalf("failed to write truncated binary: %v", err)
+	}
+	if err := verifySop(truncated, "0.5.1"); err == nil {
+		t.Error("verifySop should fail for a binary that doesn't run")
+	}
+	if err := verifySop(script("crash", "exit 3"), "nightly-2026-01-02"); err == nil {
+		t.Error("verifySop should fail for a nightly that exits with an error")
+	}
+}
+
+func TestCommandEnv(t *testing.T) {
+	t.Setenv("NO_PROXY", "localhost")
+	t.Setenv("no_proxy", "")
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "corp.pem")
+	cert := "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"
+	_err0 := os.WriteFile(certFile, []byte(cert), 0o644)
+	if _err0 != nil {
+		err := _err0
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+
+	network := config.Network{
+		Proxy: "http://proxy.corp.example:3128",
+		NoProxy: []string{"*.corp.example", "10.0.0.0/8"},
+		CACerts: []string{certFile},
+		InsecureSkipVerify: true,
+	}
+	caBundle := filepath.Join(dir, "bundle.pem")
+	env, _err1 := commandEnv(config.Config{Network: (&network)}, caBundle)
+	if _err1 != nil {
+		err := _err1
+		t.Fatalf("commandEnv failed: %v", err)
+	}
+
+	want := []string{
+		"HTTPS_PROXY=http://proxy.corp.example:3128",
+		"http_proxy=http://proxy.corp.example:3128",
+		"NO_PROXY=localhost,.corp.example,10.0.0.0/8",
+		"GIT_SSL_CAINFO=" + caBundle,
+		"CARGO_HTTP_CAINFO=" + caBundle,
+		"GIT_SSL_NO_VERIFY=true",
+	}
+	for _, entry := range want {
+		if (!slices.Contains(env, entry)) {
+			t.Errorf("commandEnv is missing %s", entry)
+		}
+	}
+	bundle, _err2 := os.ReadFile(caBundle)
+	if _err2 != nil {
+		err := _err2
+		t.Fatalf("failed to read CA bundle: %v", err)
+	}
+	if (!strings.HasSuffix(string(bundle), cert)) {
+		t.Error("CA bundle should end with the ca_certs files")
+	}
+
+	plain, _err3 := commandEnv(config.Config{}, filepath.Join(dir, "unused.pem"))
+	if _err3 != nil {
+		err := _err3
+		t.Fatalf("commandEnv without [network] failed: %v", err)
+	}
+	if len(plain) != len(os.Environ()) {
+		t.Error("commandEnv without [network] should pass the environment through unchanged")
+	}
+}
+
+func TestParseSpecs(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    []Spec
+		wantErr string
+	}{
+		{args: []string{"sop@0.5.1", "go@1.23"}, want: []Spec{{Tool: "sop", Version: "0.5.1"}, {Tool: "go", Version: "1.23"}}, wantErr: ""},
+		{args: []string{"go", "1.23", "sop@beta"}, want: []Spec{{Tool: "go", Version: "1.23"}, {Tool: "sop", Version: "beta"}}, wantErr: ""},
+		{args: []string{"sop", "0.5.1"}, want: []Spec{{Tool: "sop", Version: "0.5.1"}}, wantErr: ""},
+		{args: []string{"sop@latest", "go@1.23", "sop@latest", "go", "1.23"}, want: []Spec{{Tool: "sop", Version: "latest"}, {Tool: "go", Version: "1.23"}}, wantErr: ""},
+		{args: []string{"sop@0.5.1", "sop@0.5.2"}, want: []Spec{{Tool: "sop", Version: "0.5.1"}, {Tool: "sop", Version: "0.5.2"}}, wantErr: ""},
+		{args: []string{"go", "sop@0.5.1"}, want: nil, wantErr: "missing version for go"},
+		{args: []string{"sop@"}, want: nil, wantErr: "missing version for sop"},
+		{args: []string{"node@22"}, want: nil, wantErr: "unknown tool 'node'"},
+		{args: []string{"0.5.1"}, want: nil, wantErr: "unknown tool '0.5.1'"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSpecs(tt.args)
+		if tt.wantErr != "" {
+			if err == nil || (!strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("ParseSpecs(%q) error = %v, want %q", tt.args, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || (!slices.Equal(got, tt.want)) {
+			t.Errorf("ParseSpecs(%q) = %v, %v, want %v", tt.args, got, err, tt.want)
+		}
+	}
+}
+
+func TestInstallSpecResults(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	_err0 := os.MkdirAll(filepath.Join(home, ".sopmod", "go", "1.23.4"), 0o755)
+	if _err0 != nil {
+		err := _err0
+		t.Fatalf("failed to create go install: %v", err)
+	}
+
+	releases := map[string]string{
+		"sop@latest": "0.6.0",
+		"sop@0.6.0": "0.6.0",
+		"sop@0.5": "0.5.1",
+		"go@1.23": "1.23.4",
+	}
+	resolve := func(spec Spec) (string, error) {
+		if release, ok := releases[spec.String()]; ok {
+			return release, nil
+		}
+		return "", errors.New("no such release")
+	}
+	var mu sync.Mutex
+	fetched := []Spec{}
+	fetch := func(spec Spec) error {
+		mu.Lock()
+		fetched = append(fetched, spec)
+		mu.Unlock()
+		if spec.Version == "0.5.1" {
+			return errors.New("download failed")
+		}
+		return nil
+	}
+
+	specs := []Spec{{Tool: "sop", Version: "latest"}, {Tool: "sop", Version: "0.6.0"}, {Tool: "go", Version: "1.23"}, {Tool: "sop", Version: "beta"}, {Tool: "sop", Version: "0.5"}}
+	results := ResolveSpecs(specs, resolve)
+	RunJobs(FetchJobs(results, fetch), Workers)
+	failed := Settle(results)
+
+	slices.SortFunc(fetched, func(a, b Spec) int {
+		return strings.Compare(a.String(), b.String())
+	})
+	wantFetched := []Spec{{Tool: "go", Version: "1.23.4"}, {Tool: "sop", Version: "0.5.1"}, {Tool: "sop", Version: "0.6.0"}}
+	if (!slices.Equal(fetched, wantFetched)) {
+		t.Errorf("fetched %v, want each release once: %v", fetched, wantFetched)
+	}
+	if failed != 2 {
+		t.Errorf("Settle = %d failed, want 2", failed)
+	}
+
+	tests := []struct {
+		resolved string
+		status   string
+		err      string
+	}{
+		{resolved: "0.6.0", status: "installed", err: ""},
+		{resolved: "0.6.0", status: "installed", err: ""},
+		{resolved: "1.23.4", status: "already installed", err: ""},
+		{resolved: "", status: "", err: "no such release"},
+		{resolved: "0.5.1", status: "installed", err: "download failed"},
+	}
+	for i, tt := range tests {
+		got := results[i]
+		errText := ""
+		if got.Err != nil {
+			errText = got.Err.Error()
+		}
+		if got.Resolved != tt.resolved || got.Status != tt.status || errText != tt.err {
+			t.Errorf("%s = %q, %q, %v, want %q, %q, %q", got.Spec, got.Resolved, got.Status, got.Err, tt.resolved, tt.status, tt.err)
+		}
+	}
+}
+
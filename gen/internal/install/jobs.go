@@ -0,0 +1,45 @@
+//soppo:generated v1
+package install
+
+import "errors"
+import "fmt"
+import "sync"
+
+// Workers is how many downloads run at once. Enough to overlap sop, sopls
+// and go without hammering GitHub or go.dev.
+const Workers = 4
+
+// Job is one install that can run alongside others
+type Job struct {
+	Name string
+	Run func() error
+}
+// Name: What's being installed, e.g. "go 1.23.4", to label its error
+
+// RunJobs runs jobs on at most workers goroutines and waits for all of them.
+// A failure doesn't stop the other jobs; every failure comes back, labelled
+// and in job order, joined into one error.
+func RunJobs(jobs []Job, workers int) error {
+	errs := make([]error, len(jobs))
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, len(jobs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				if err := jobs[i].Run(); err != nil {
+					errs[i] = fmt.Errorf("%s: %w", jobs[i].Name, err)
+				}
+			}
+		}()
+	}
+
+	for i := range jobs {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
@@ -0,0 +1,103 @@
+//soppo:generated v1
+package install
+
+import "errors"
+import "fmt"
+import "io/fs"
+import "os"
+import "path/filepath"
+import "strings"
+import "time"
+import "github.com/halcyonnouveau/sopmod/gen/internal/ui"
+
+// staleLockAge is how long a lock can go untouched before it's taken to be
+// left behind by a sopmod that died. Holders touch it well within this.
+const staleLockAge = 2 * time.Minute
+
+// lockPollInterval is how often a waiting install checks the lock again
+const lockPollInterval = 250 * time.Millisecond
+
+// stagingMarker is in the name of a directory an install is being written
+// to, before it's renamed into place
+const stagingMarker = ".tmp-"
+
+// lockDir takes the lock for writing a version directory, waiting while
+// another sopmod, or another install in this one, holds it. The lock is a
+// file beside dir, so it works the same on every platform. Call the returned
+// func to release it.
+func lockDir(dir string) (func(), error) {
+	path := dir + ".lock"
+	_err0 := os.MkdirAll(filepath.Dir(path), 0o755)
+	if _err0 != nil {
+		return nil, _err0
+	}
+
+	waiting := false
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE | os.O_EXCL | os.O_WRONLY, 0o644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return holdLock(path), nil
+		}
+		if (!errors.Is(err, fs.ErrExist)) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			os.Remove(path)
+			continue
+		}
+		if (!waiting) {
+			ui.Progress("Waiting for another sopmod writing %s", dir)
+			waiting = true
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// holdLock keeps the lock at path fresh until the returned func releases it,
+// so a long download or build isn't mistaken for a dead one
+func holdLock(path string) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(staleLockAge / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				os.Chtimes(path, now, now)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+		os.Remove(path)
+	}
+}
+
+// stagingDir makes an empty directory beside dest for an install to fill,
+// first clearing out any an interrupted install left. The caller must hold
+// dest's lock.
+func stagingDir(dest string) (string, error) {
+	leftovers, _err0 := filepath.Glob(dest + stagingMarker + "*")
+	if _err0 != nil {
+		return "", _err0
+	}
+	for _, dir := range leftovers {
+		os.RemoveAll(dir)
+	}
+	return os.MkdirTemp(filepath.Dir(dest), filepath.Base(dest) + stagingMarker + "*")
+}
+
+// isStaging reports whether name is a directory an install is still filling
+func isStaging(name string) bool {
+	return strings.Contains(name, stagingMarker)
+}
+
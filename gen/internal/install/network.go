@@ -0,0 +1,226 @@
+//soppo:generated v1
+package install
+
+import "crypto/tls"
+import "crypto/x509"
+import "fmt"
+import "net"
+import "net/http"
+import "net/url"
+import "os"
+import "strings"
+import "sync"
+import "github.com/halcyonnouveau/sopmod/gen/internal/config"
+import "github.com/halcyonnouveau/sopmod/gen/internal/ui"
+
+// httpClient is what every request goes through, built on first use from the
+// [network] table of config.toml
+var httpClient = sync.OnceValues(func() (*http.Client, error) {
+	cfg := config.Load()
+	if cfg.Network == nil {
+		return http.DefaultClient, nil
+	}
+	if cfg.Network.InsecureSkipVerify {
+		ui.Warn("TLS certificate verification is off (insecure_skip_verify in config.toml)")
+	}
+	return NewClient((*cfg.Network))
+})
+
+// NewClient builds an HTTP client from the [network] settings. The proxy,
+// when set, wins over HTTPS_PROXY and friends, and no_proxy applies on top
+// of NO_PROXY either way. Certificates in ca_certs are trusted alongside the
+// system roots.
+func NewClient(network config.Network) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	proxy := http.ProxyFromEnvironment
+	if network.Proxy != "" {
+		proxyURL, _err0 := parseProxy(network.Proxy)
+		if _err0 != nil {
+			return nil, _err0
+		}
+		proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyURL, nil
+		}
+	}
+	noProxy := network.NoProxy
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		if bypassProxy(req.URL.Hostname(), noProxy) {
+			return nil, nil
+		}
+		return proxy(req)
+	}
+
+	if len(network.CACerts) > 0 || network.InsecureSkipVerify {
+		tlsConfig := (&tls.Config{InsecureSkipVerify: network.InsecureSkipVerify})
+		if len(network.CACerts) > 0 {
+			var _err1 error
+			tlsConfig.RootCAs, _err1 = loadCACerts(network.CACerts)
+			if _err1 != nil {
+				return nil, _err1
+			}
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return (&http.Client{Transport: transport}), nil
+}
+
+// parseProxy expands $VARS in a proxy setting, so a password can stay out of
+// config.toml, and checks it's a URL the transport can use
+func parseProxy(proxy string) (*url.URL, error) {
+	proxyURL, err := url.Parse(os.ExpandEnv(proxy))
+	if err != nil || proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid proxy '%s' in config.toml. Use a URL like http://proxy.example.com:3128", proxy)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+		return proxyURL, nil
+	}
+	return nil, fmt.Errorf("unsupported proxy scheme '%s' in config.toml. Use http, https or socks5", proxyURL.Scheme)
+}
+
+// loadCACerts adds the PEM files to the system roots
+func loadCACerts(files []string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	for _, file := range files {
+		data, _err0 := os.ReadFile(file)
+		if _err0 != nil {
+			err := _err0
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		if (!pool.AppendCertsFromPEM(data)) {
+			return nil, fmt.Errorf("no PEM certificates in %s", file)
+		}
+	}
+	return pool, nil
+}
+
+// bypassProxy reports whether host matches a no_proxy entry: "*", an exact
+// host, a domain covering its subdomains (with or without a leading dot), or
+// a CIDR range for IP hosts
+func bypassProxy(host string, noProxy []string) bool {
+	host = strings.ToLower(host)
+	for _, entry := range noProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "*" {
+			return true
+		}
+		if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+			if ip := net.ParseIP(host); ip != nil && ipNet.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		domain := strings.TrimPrefix(strings.TrimPrefix(entry, "*"), ".")
+		if domain != "" && (host == domain || strings.HasSuffix(host, "." + domain)) {
+			return true
+		}
+	}
+	return false
+}
+
+// systemCABundles are where Unix systems keep their trusted roots as one file,
+// the base of the bundle git and cargo get when ca_certs is set
+var systemCABundles = []string{
+	"/etc/ssl/certs/ca-certificates.crt",
+	"/etc/pki/tls/certs/ca-bundle.crt",
+	"/etc/ssl/ca-bundle.pem",
+	"/etc/pki/tls/cacert.pem",
+	"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem",
+	"/etc/ssl/cert.pem",
+}
+
+// commandEnv returns the environment for git and cargo, which make their own
+// requests, with the [network] settings in the variables they read. When
+// ca_certs is set, the system roots and those certificates are written to
+// caBundle for them. cargo has no way to skip verification, so
+// insecure_skip_verify only reaches git.
+func commandEnv(cfg config.Config, caBundle string) ([]string, error) {
+	env := os.Environ()
+	if cfg.Network == nil {
+		return env, nil
+	}
+	network := (*cfg.Network)
+
+	if network.Proxy != "" {
+		proxyURL, _err0 := parseProxy(network.Proxy)
+		if _err0 != nil {
+			return nil, _err0
+		}
+		for _, key := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"} {
+			env = append(env, key + "=" + proxyURL.String())
+		}
+	}
+
+	if len(network.NoProxy) > 0 {
+		entries := []string{}
+		for _, key := range []string{"NO_PROXY", "no_proxy"} {
+			if value := os.Getenv(key); value != "" {
+				entries = append(entries, value)
+				break
+			}
+		}
+		for _, entry := range network.NoProxy {
+			entries = append(entries, strings.TrimPrefix(strings.TrimSpace(entry), "*"))
+		}
+		noProxy := strings.Join(entries, ",")
+		env = append(env, "NO_PROXY=" + noProxy, "no_proxy=" + noProxy)
+	}
+
+	if len(network.CACerts) > 0 {
+		_err1 := writeCABundle(caBundle, network.CACerts)
+		if _err1 != nil {
+			return nil, _err1
+		}
+		env = append(env, "GIT_SSL_CAINFO=" + caBundle, "CARGO_HTTP_CAINFO=" + caBundle)
+	}
+	if network.InsecureSkipVerify {
+		env = append(env, "GIT_SSL_NO_VERIFY=true")
+	}
+	return env, nil
+}
+
+// writeCABundle writes the system roots, where the system keeps them in a
+// file, followed by the given PEM files to path
+func writeCABundle(path string, files []string) error {
+	var bundle []byte
+	for _, system := range systemCABundles {
+		if data, err := os.ReadFile(system); err == nil {
+			bundle = append(bundle, data...)
+			break
+		}
+	}
+	for _, file := range files {
+		data, _err0 := os.ReadFile(file)
+		if _err0 != nil {
+			err := _err0
+			return fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		bundle = append(bundle, '\n')
+		bundle = append(bundle, data...)
+	}
+	return os.WriteFile(path, bundle, 0o600)
+}
+
+// do sends req through the client configured from config.toml
+func do(req *http.Request) (*http.Response, error) {
+	client, _err0 := httpClient()
+	if _err0 != nil {
+		return nil, _err0
+	}
+	return client.Do(req)
+}
+
+// get fetches url through the client configured from config.toml
+func get(url string) (*http.Response, error) {
+	req, _err0 := http.NewRequest("GET", url, nil)
+	if _err0 != nil {
+		return nil, _err0
+	}
+	return do(req)
+}
+
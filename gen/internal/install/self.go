@@ -0,0 +1,187 @@
+//soppo:generated v1
+package install
+
+import "crypto/sha256"
+import "encoding/hex"
+import "encoding/json"
+import "fmt"
+import "io"
+import "net/http"
+import "os"
+import "path/filepath"
+import "runtime"
+import "strings"
+import "github.com/halcyonnouveau/sopmod/gen/internal/ui"
+
+const sopmodReleasesURL = "https://api.github.com/repos/halcyonnouveau/sopmod/releases/latest"
+
+// UpdateSopmod replaces the running sopmod with the latest release.
+// The archive is checked against the SHA-256 digest GitHub publishes for it before
+// anything is touched. Returns the path of the replaced binary and the version it
+// now holds, or "" when current is already the latest release.
+func UpdateSopmod(current string, verbose bool) (string, string, error) {
+	platform, _err0 := DetectPlatform()
+	if _err0 != nil {
+		return "", "", _err0
+	}
+
+	req, _err1 := http.NewRequest("GET", sopmodReleasesURL, nil)
+	if _err1 != nil {
+		return "", "", _err1
+	}
+	req.Header.Set("User-Agent", "sopmod")
+
+	resp, _err2 := do(req)
+	if _err2 != nil {
+		return "", "", _err2
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("failed to fetch the latest sopmod release: %s", resp.Status)
+	}
+
+	var release GitHubRelease
+	_err3 := json.NewDecoder(resp.Body).Decode((&release))
+	if _err3 != nil {
+		return "", "", _err3
+	}
+
+	if strings.TrimPrefix(release.TagName, "v") == strings.TrimPrefix(current, "v") {
+		ui.Success("sopmod %s is already the latest version", ui.Err.Bold(current))
+		return "", "", nil
+	}
+
+	ext := "tar.gz"
+	if platform.OS == "windows" {
+		ext = "zip"
+	}
+	assetName := fmt.Sprintf("sopmod-%s-%s.%s", platform.OS, platform.Arch, ext)
+
+	var asset *GitHubAsset
+	for i := range release.Assets {
+		if release.Assets[i].Name == assetName {
+			asset = (&release.Assets[i])
+			break
+		}
+	}
+	if asset == nil {
+		return "", "", fmt.Errorf("sopmod %s has no release for %s-%s", release.TagName, platform.OS, platform.Arch)
+	}
+
+	wantSum, ok := strings.CutPrefix(asset.Digest, "sha256:")
+	if (!ok) {
+		return "", "", fmt.Errorf("sopmod %s has no checksum for %s, refusing to install it", release.TagName, assetName)
+	}
+
+	if verbose {
+		ui.Progress("Downloading sopmod %s from %s", release.TagName, asset.BrowserDownloadURL)
+	}
+
+	dlReq, _err4 := http.NewRequest("GET", asset.BrowserDownloadURL, nil)
+	if _err4 != nil {
+		return "", "", _err4
+	}
+	dlReq.Header.Set("User-Agent", "sopmod")
+
+	dlResp, _err5 := do(dlReq)
+	if _err5 != nil {
+		return "", "", _err5
+	}
+	defer dlResp.Body.Close()
+
+	if dlResp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("failed to download %s: %s", assetName, dlResp.Status)
+	}
+
+	tmpFile, _err6 := os.CreateTemp("", "sopmod-*." + ext)
+	if _err6 != nil {
+		return "", "", _err6
+	}
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+
+	ui.Progress("Downloading sopmod %s", release.TagName)
+	hash := sha256.New()
+	_, _err7 := io.Copy(io.MultiWriter(tmpFile, hash), dlResp.Body)
+	if _err7 != nil {
+		return "", "", _err7
+	}
+	tmpFile.Close()
+
+	if gotSum := hex.EncodeToString(hash.Sum(nil)); gotSum != wantSum {
+		return "", "", fmt.Errorf("checksum mismatch for %s: got %s, want %s", assetName, gotSum, wantSum)
+	}
+
+	tmpDir, _err8 := os.MkdirTemp("", "sopmod-")
+	if _err8 != nil {
+		return "", "", _err8
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if ext == "zip" {
+		_err9 := extractZip(tmpFile.Name(), tmpDir)
+		if _err9 != nil {
+			return "", "", _err9
+		}
+	} else {
+		_err10 := extractTarGz(tmpFile.Name(), tmpDir)
+		if _err10 != nil {
+			return "", "", _err10
+		}
+	}
+
+	newBinary := filepath.Join(tmpDir, exeName("sopmod"))
+	if (!fileExists(newBinary)) {
+		return "", "", fmt.Errorf("%s does not contain a sopmod binary", assetName)
+	}
+
+	exe, _err11 := os.Executable()
+	if _err11 != nil {
+		return "", "", _err11
+	}
+	var _err12 error
+	exe, _err12 = filepath.EvalSymlinks(exe)
+	if _err12 != nil {
+		return "", "", _err12
+	}
+	_err13 := replaceExecutable(exe, newBinary)
+	if _err13 != nil {
+		return "", "", _err13
+	}
+
+	ui.Success("sopmod updated from %s to %s", ui.Err.Bold(current), ui.Err.Bold(release.TagName))
+	return exe, release.TagName, nil
+}
+
+// replaceExecutable swaps the binary at path for newBinary with a single rename,
+// so an interrupted update never leaves a half-written sopmod behind
+func replaceExecutable(path string, newBinary string) error {
+	// Stage next to the target so the rename stays on one filesystem
+	staged := path + ".new"
+	_err0 := copyFile(newBinary, staged)
+	if _err0 != nil {
+		return _err0
+	}
+	_err1 := os.Chmod(staged, 0o755)
+	if _err1 != nil {
+		err := _err1
+		os.Remove(staged)
+		return err
+	}
+
+	// Windows can't replace a running executable, but it can move it out of the way
+	if runtime.GOOS == "windows" {
+		old := path + ".old"
+		os.Remove(old)
+		_err2 := os.Rename(path, old)
+		if _err2 != nil {
+			err := _err2
+			os.Remove(staged)
+			return err
+		}
+	}
+
+	return os.Rename(staged, path)
+}
+
@@ -0,0 +1,160 @@
+//soppo:generated v1
+package install
+
+import "fmt"
+import "slices"
+import "strings"
+
+// Spec is one version of one tool to install
+type Spec struct {
+	Tool string
+	Version string
+}
+
+func (s Spec) String() string {
+	return s.Tool + "@" + s.Version
+}
+
+// Result is how one requested spec went, for the summary
+type Result struct {
+	Spec Spec
+	Resolved string
+	Status string
+	Err error
+}
+// Resolved: Empty when resolving failed
+// Status: installed or already installed, empty when Err is set
+
+// ParseSpecs reads tool@version specs, and the older `tool version` pair, in
+// the order given and without repeats
+//
+// ```sop
+// import "fmt"
+// specs := ParseSpecs([]string{"sop@0.5.1", "go", "1.23", "sop@0.5.1"}) ? err {
+// 	panic(err)
+// }
+// fmt.Println(specs)
+// // Output:
+// // [sop@0.5.1 go@1.23]
+// ```
+func ParseSpecs(args []string) ([]Spec, error) {
+	specs := []Spec{}
+	for i := 0; i < len(args); i++ {
+		tool, version, found := strings.Cut(args[i], "@")
+		if tool != "go" && tool != "sop" {
+			return nil, fmt.Errorf("unknown tool '%s'. Use go@<version> or sop@<version>", tool)
+		}
+		if (!found) && i + 1 < len(args) && (!strings.Contains(args[i + 1], "@")) {
+			version = args[i + 1]
+			i++
+		}
+		if version == "" {
+			return nil, fmt.Errorf("missing version for %s, e.g. `sopmod install %s@latest`", tool, tool)
+		}
+
+		spec := Spec{Tool: tool, Version: version}
+		if (!slices.Contains(specs, spec)) {
+			specs = append(specs, spec)
+		}
+	}
+	return specs, nil
+}
+
+// ResolveSpec turns latest, channels and go prefixes into the version to install
+func ResolveSpec(spec Spec) (string, error) {
+	switch spec.Tool {
+	case "go":
+		return ResolveGoVersion(spec.Version)
+	case "sop":
+		return ResolveSopVersion(spec.Version)
+	}
+	return "", fmt.Errorf("unknown tool '%s'. Use 'go' or 'sop'", spec.Tool)
+}
+
+// InstallSpec installs a go or sop version ResolveSpec has already resolved
+func InstallSpec(spec Spec, verbose bool) error {
+	switch spec.Tool {
+	case "go":
+		return InstallResolvedGo(spec.Version, verbose)
+	case "sop":
+		return InstallResolvedSop(spec.Version, verbose)
+	}
+	return fmt.Errorf("unknown tool '%s'. Use 'go' or 'sop'", spec.Tool)
+}
+
+// ResolveSpecs resolves every spec with resolve, all at once
+func ResolveSpecs(specs []Spec, resolve func(Spec) (string, error)) []Result {
+	results := make([]Result, len(specs))
+	jobs := []Job{}
+	for i, spec := range specs {
+		results[i].Spec = spec
+		jobs = append(jobs, Job{Name: spec.String(), Run: func() error {
+			results[i].Resolved, results[i].Err = resolve(spec)
+			return results[i].Err
+		}})
+	}
+	RunJobs(jobs, Workers)
+	return results
+}
+
+// FetchJobs returns a job per distinct release the results resolved to, so
+// sop@latest and sop@0.6.0 landing on the same release fetch it once. Once
+// they've run, Settle hands each outcome to the other specs that share it.
+func FetchJobs(results []Result, fetch func(Spec) error) []Job {
+	seen := map[Spec]bool{}
+	jobs := []Job{}
+	for i := range results {
+		if results[i].Err != nil {
+			continue
+		}
+		target := results[i].release()
+		if seen[target] {
+			continue
+		}
+		seen[target] = true
+		jobs = append(jobs, Job{Name: target.String(), Run: func() error {
+			results[i].Status = "installed"
+			if isInstalled(target) {
+				results[i].Status = "already installed"
+			}
+			results[i].Err = fetch(target)
+			return results[i].Err
+		}})
+	}
+	return jobs
+}
+
+// Settle gives every result the outcome of the job that fetched its release
+// and returns how many failed
+func Settle(results []Result) int {
+	firsts := map[Spec]int{}
+	failed := 0
+	for i := range results {
+		if results[i].Resolved != "" {
+			target := results[i].release()
+			if first, ok := firsts[target]; ok {
+				results[i].Status, results[i].Err = results[first].Status, results[first].Err
+			} else {
+				firsts[target] = i
+			}
+		}
+		if results[i].Err != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+// release is the exact version a result resolved to
+func (r Result) release() Spec {
+	return Spec{Tool: r.Spec.Tool, Version: r.Resolved}
+}
+
+// isInstalled reports whether an exact version of a tool is installed
+func isInstalled(spec Spec) bool {
+	if spec.Tool == "go" {
+		return slices.Contains(ListInstalledGo(), spec.Version)
+	}
+	return slices.Contains(ListInstalledSop(), spec.Version)
+}
+
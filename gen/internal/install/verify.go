@@ -0,0 +1,72 @@
+//soppo:generated v1
+package install
+
+import "context"
+import "fmt"
+import "os"
+import "os/exec"
+import "path/filepath"
+import "strings"
+import "time"
+
+// versionCheckTimeout bounds how long a freshly installed binary gets to
+// report its version
+const versionCheckTimeout = 30 * time.Second
+
+// verifyGo runs the go binary just installed and checks it's the release that
+// was asked for
+func verifyGo(bin string, version string) error {
+	output, _err0 := runVersion(bin, "version")
+	if _err0 != nil {
+		return _err0
+	}
+	if (!reportsVersion(output, "go" + version)) {
+		return fmt.Errorf("`go version` reports %q, not go %s", output, version)
+	}
+	return nil
+}
+
+// verifySop runs the sop binary just installed and checks it's the release
+// that was asked for. A nightly reports the version it's building towards,
+// so for those it's enough that the binary runs.
+func verifySop(bin string, version string) error {
+	output, _err0 := runVersion(bin, "--version")
+	if _err0 != nil {
+		return _err0
+	}
+	if (!isNightly(version)) && (!reportsVersion(output, version)) {
+		return fmt.Errorf("`sop --version` reports %q, not sop %s", output, version)
+	}
+	return nil
+}
+
+// runVersion runs bin with args and returns its trimmed output. It fails when
+// the binary can't run, say because the download was cut short or is built
+// for another platform.
+func runVersion(bin string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), versionCheckTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Dir = filepath.Dir(bin)
+	// Keep go from switching to another toolchain for GOTOOLCHAIN or a go.mod
+	cmd.Env = append(os.Environ(), "GOTOOLCHAIN=local")
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s %s failed: %s\n%s", bin, strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// reportsVersion reports whether a word of a binary's version output is want,
+// with or without a leading "v"
+func reportsVersion(output string, want string) bool {
+	for _, field := range strings.Fields(output) {
+		if strings.TrimPrefix(field, "v") == want {
+			return true
+		}
+	}
+	return false
+}
+
@@ -123,6 +123,19 @@ func SoplsBinary(version string) string {
 	return filepath.Join(dir, "sopls")
 }
 
+// SopSourceFile returns the file recording where a custom sop toolchain came from.
+// Released versions don't have one; linked and git-built toolchains do.
+//
+// ```sop,no_run
+// import "fmt"
+// fmt.Println(SopSourceFile("dev"))
+// // Output:
+// // /home/user/.sopmod/sop/dev/.source
+// ```
+func SopSourceFile(version string) string {
+	return filepath.Join(SopDir(version), ".source")
+}
+
 // ConfigPath returns the config file path (~/.sopmod/config.toml).
 //
 // ```sop,no_run
@@ -135,6 +148,57 @@ func ConfigPath() string {
 	return filepath.Join(SopmodDir(), "config.toml")
 }
 
+// ProjectsPath returns the registry of projects the shim has run in (~/.sopmod/projects).
+//
+// ```sop,no_run
+// import "fmt"
+// fmt.Println(ProjectsPath())
+// // Output:
+// // /home/user/.sopmod/projects
+// ```
+func ProjectsPath() string {
+	return filepath.Join(SopmodDir(), "projects")
+}
+
+// CompatFile returns the hand-written Go compatibility manifest (~/.sopmod/compat.toml).
+// When present it takes precedence over the fetched and built-in manifests.
+//
+// ```sop,no_run
+// import "fmt"
+// fmt.Println(CompatFile())
+// // Output:
+// // /home/user/.sopmod/compat.toml
+// ```
+func CompatFile() string {
+	return filepath.Join(SopmodDir(), "compat.toml")
+}
+
+// CompatCacheFile returns the Go compatibility manifest fetched from the latest
+// soppo release (~/.sopmod/cache/compat.toml).
+//
+// ```sop,no_run
+// import "fmt"
+// fmt.Println(CompatCacheFile())
+// // Output:
+// // /home/user/.sopmod/cache/compat.toml
+// ```
+func CompatCacheFile() string {
+	return filepath.Join(SopmodDir(), "cache", "compat.toml")
+}
+
+// LocalFile returns the per-directory overrides set with `sopmod local`
+// (~/.sopmod/local.toml).
+//
+// ```sop,no_run
+// import "fmt"
+// fmt.Println(LocalFile())
+// // Output:
+// // /home/user/.sopmod/local.toml
+// ```
+func LocalFile() string {
+	return filepath.Join(SopmodDir(), "local.toml")
+}
+
 // BinDir returns the bin directory for the shim (~/.sopmod/bin).
 // This directory should be added to PATH.
 //
@@ -182,6 +246,19 @@ func SoplsShim() string {
 	return filepath.Join(dir, "sopls")
 }
 
+// ShimVersionFile returns the file recording which sopmod version installed the shims
+// (~/.sopmod/bin/.shim-version).
+//
+// ```sop,no_run
+// import "fmt"
+// fmt.Println(ShimVersionFile())
+// // Output:
+// // /home/user/.sopmod/bin/.shim-version
+// ```
+func ShimVersionFile() string {
+	return filepath.Join(BinDir(), ".shim-version")
+}
+
 // EnsureDirs creates the sopmod directory structure.
 // Creates ~/.sopmod/go, ~/.sopmod/sop, and ~/.sopmod/bin.
 //
@@ -115,6 +115,16 @@ func TestSopBinary(t *testing.T) {
 	}
 }
 
+func TestSopSourceFile(t *testing.T) {
+	got := SopSourceFile("dev")
+	if (!strings.HasPrefix(got, SopDir("dev"))) {
+		t.Errorf("SopSourceFile(dev) = %q should be under SopDir(dev) = %q", got, SopDir("dev"))
+	}
+	if (!strings.HasSuffix(got, ".source")) {
+		t.Errorf("SopSourceFile(dev) = %q, want suffix .source", got)
+	}
+}
+
 func TestConfigPath(t *testing.T) {
 	got := ConfigPath()
 	if (!strings.HasSuffix(got, "config.toml")) {
@@ -125,6 +135,31 @@ func TestConfigPath(t *testing.T) {
 	}
 }
 
+func TestProjectsPath(t *testing.T) {
+	got := ProjectsPath()
+	if (!strings.HasSuffix(got, "projects")) {
+		t.Errorf("ProjectsPath() = %q, want suffix projects", got)
+	}
+	if (!strings.Contains(got, ".sopmod")) {
+		t.Errorf("ProjectsPath() = %q, should contain .sopmod", got)
+	}
+}
+
+func TestCompatFiles(t *testing.T) {
+	if got := CompatFile(); (!strings.HasPrefix(got, SopmodDir())) || (!strings.HasSuffix(got, "compat.toml")) {
+		t.Errorf("CompatFile() = %q, want compat.toml in SopmodDir() = %q", got, SopmodDir())
+	}
+	if got := CompatCacheFile(); got == CompatFile() || (!strings.HasSuffix(got, "compat.toml")) {
+		t.Errorf("CompatCacheFile() = %q, want a compat.toml apart from CompatFile()", got)
+	}
+}
+
+func TestLocalFile(t *testing.T) {
+	if got := LocalFile(); !strings.HasPrefix(got, SopmodDir()) || !strings.HasSuffix(got, "local.toml") {
+		t.Errorf("LocalFile() = %q, want local.toml in SopmodDir() = %q", got, SopmodDir())
+	}
+}
+
 func TestBinDir(t *testing.T) {
 	got := BinDir()
 	if (!strings.HasSuffix(got, ".sopmod/bin")) && (!strings.HasSuffix(got, ".sopmod\\bin")) {
@@ -132,6 +167,13 @@ func TestBinDir(t *testing.T) {
 	}
 }
 
+func TestShimVersionFile(t *testing.T) {
+	got := ShimVersionFile()
+	if (!strings.HasPrefix(got, BinDir())) || (!strings.HasSuffix(got, ".shim-version")) {
+		t.Errorf("ShimVersionFile() = %q, want .shim-version in BinDir() = %q", got, BinDir())
+	}
+}
+
 func TestSopShim(t *testing.T) {
 	got := SopShim()
 
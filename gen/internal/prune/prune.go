@@ -0,0 +1,99 @@
+//soppo:generated v1
+package prune
+
+import "fmt"
+import "sort"
+import "github.com/halcyonnouveau/sopmod/gen/internal/compat"
+import "github.com/halcyonnouveau/sopmod/gen/internal/config"
+import "github.com/halcyonnouveau/sopmod/gen/internal/shim"
+
+// UsedVersions returns the installed sop and go versions that the config or
+// a project refers to. Local overrides count as projects.
+func UsedVersions(cfg config.Config, projects []*config.ProjectConfig, installedSop []string, installedGo []string) (map[string]bool, map[string]bool) {
+	usedSop := map[string]bool{}
+	usedGo := map[string]bool{}
+	useSop := func(wanted string) {
+		if channelVersion, ok := cfg.Channels[wanted]; ok {
+			wanted = channelVersion
+		}
+		if v := shim.ResolveInstalledVersion(wanted, installedSop); v != "" {
+			usedSop[v] = true
+		}
+	}
+	useGo := func(wanted string) {
+		if v := shim.ResolveInstalledGo(wanted, installedGo); v != "" {
+			usedGo[v] = true
+		}
+	}
+
+	if cfg.DefaultSop != nil {
+		useSop((*cfg.DefaultSop))
+	}
+	if cfg.DefaultGo != nil {
+		useGo((*cfg.DefaultGo))
+	}
+	for _, v := range cfg.Channels {
+		useSop(v)
+	}
+	for _, tc := range cfg.Toolchains {
+		useSop(tc.Sop)
+		useGo(tc.Go)
+	}
+
+	for _, projectCfg := range projects {
+		if projectCfg.Sop != nil {
+			useSop((*projectCfg.Sop))
+		}
+		if projectCfg.Go != nil {
+			useGo((*projectCfg.Go))
+		}
+	}
+
+	// Never remove the go the default sop runs with, even when it was picked implicitly
+	if cfg.DefaultSop != nil && cfg.DefaultGo == nil && cfg.DefaultToolchain == nil {
+		if best := shim.BestCompatibleGo((*cfg.DefaultSop), installedGo); best != "" {
+			usedGo[best] = true
+		}
+	}
+
+	return usedSop, usedGo
+}
+
+// Candidates returns the unused versions outside the newest keep, newest first
+func Candidates(installed []string, used map[string]bool, keep int) []string {
+	sorted := append([]string{}, installed...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return compat.CompareVersions(sorted[i], sorted[j]) > 0
+	})
+
+	candidates := []string{}
+	for i, v := range sorted {
+		if i < keep || used[v] {
+			continue
+		}
+		candidates = append(candidates, v)
+	}
+	return candidates
+}
+
+// FormatSize renders a byte count for humans, e.g. "1.5 GB"
+//
+// ```sop
+// import "fmt"
+// fmt.Println(FormatSize(512), FormatSize(1536), FormatSize(3 << 30))
+// // Output:
+// // 512 B 1.5 KB 3.0 GB
+// ```
+func FormatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
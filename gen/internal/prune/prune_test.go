@@ -0,0 +1,95 @@
+//soppo:generated v1
+package prune
+
+import "maps"
+import "slices"
+import "testing"
+import "github.com/halcyonnouveau/sopmod/gen/internal/config"
+
+func TestUsedVersions(t *testing.T) {
+	// Judge go compatibility by the built-in manifest, not one in ~/.sopmod
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	installedSop := []string{"0.4.2", "0.5.0", "0.5.1", "0.6.0-beta2", "0.6.0"}
+	installedGo := []string{"1.20.3", "1.21.5", "1.22.3", "1.23.1"}
+	pin := func(v string) *string {
+		return &v
+	}
+	legacy := map[string]config.Toolchain{"legacy": {Sop: "0.4", Go: "1.21"}}
+
+	tests := []struct {
+		name     string
+		cfg      config.Config
+		projects []*config.ProjectConfig
+		wantSop  []string
+		wantGo   []string
+	}{
+		{name: "nothing configured", cfg: config.Config{}, projects: nil, wantSop: nil, wantGo: nil},
+		{name: "default sop and the go it runs with", cfg: config.Config{DefaultSop: pin("0.5")}, projects: nil, wantSop: []string{"0.5.1"}, wantGo: []string{"1.23.1"}},
+		{name: "default go", cfg: config.Config{DefaultSop: pin("0.5.1"), DefaultGo: pin("1.22")}, projects: nil, wantSop: []string{"0.5.1"}, wantGo: []string{"1.22.3"}},
+		{name: "channel pin", cfg: config.Config{Channels: map[string]string{"beta": "0.6.0-beta2"}}, projects: []*config.ProjectConfig{{Sop: pin("beta")}}, wantSop: []string{"0.6.0-beta2"}, wantGo: nil},
+		{name: "toolchain", cfg: config.Config{Toolchains: legacy}, projects: nil, wantSop: []string{"0.4.2"}, wantGo: []string{"1.21.5"}},
+		{name: "default toolchain", cfg: config.Config{DefaultSop: pin("0.5.0"), DefaultToolchain: pin("legacy"), Toolchains: legacy}, projects: nil, wantSop: []string{"0.4.2", "0.5.0"}, wantGo: []string{"1.21.5"}},
+		{name: "project and local override", cfg: config.Config{}, projects: []*config.ProjectConfig{{Sop: pin("0.6.0")}, {Go: pin("1.20")}}, wantSop: []string{"0.6.0"}, wantGo: []string{"1.20.3"}},
+		{name: "pins nothing installed matches", cfg: config.Config{DefaultSop: pin("0.7"), DefaultGo: pin("1.24")}, projects: nil, wantSop: nil, wantGo: nil},
+	}
+
+	for _, tt := range tests {
+		usedSop, usedGo := UsedVersions(tt.cfg, tt.projects, installedSop, installedGo)
+		gotSop := slices.Sorted(maps.Keys(usedSop))
+		gotGo := slices.Sorted(maps.Keys(usedGo))
+		if (!slices.Equal(gotSop, tt.wantSop)) || (!slices.Equal(gotGo, tt.wantGo)) {
+			t.Errorf("%s: UsedVersions = sop %v, go %v, want sop %v, go %v", tt.name, gotSop, gotGo, tt.wantSop, tt.wantGo)
+		}
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	tests := []struct {
+		installed []string
+		used      []string
+		keep      int
+		want      []string
+	}{
+		{installed: []string{"0.4.2", "0.5.1", "0.6.0", "0.5.0"}, used: []string{"0.5.0"}, keep: 0, want: []string{"0.6.0", "0.5.1", "0.4.2"}},
+		{installed: []string{"0.4.2", "0.5.1", "0.6.0", "0.5.0"}, used: []string{"0.5.0"}, keep: 1, want: []string{"0.5.1", "0.4.2"}},
+		{installed: []string{"0.4.2", "0.5.1", "0.6.0", "0.5.0"}, used: []string{"0.5.0"}, keep: 2, want: []string{"0.4.2"}},
+		{installed: []string{"0.4.2", "0.5.1"}, used: nil, keep: 5, want: []string{}},
+		{installed: []string{"1.22.3", "1.23rc1", "1.23.0"}, used: nil, keep: 1, want: []string{"1.23rc1", "1.22.3"}},
+		{installed: []string{"1.22.3", "1.23.0"}, used: []string{"1.22.3", "1.23.0"}, keep: 0, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		used := map[string]bool{}
+		for _, v := range tt.used {
+			used[v] = true
+		}
+		got := Candidates(tt.installed, used, tt.keep)
+		if (!slices.Equal(got, tt.want)) {
+			t.Errorf("Candidates(%v, used %v, keep %d) = %v, want %v", tt.installed, tt.used, tt.keep, got, tt.want)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		bytes int64
+		want  string
+	}{
+		{bytes: 0, want: "0 B"},
+		{bytes: 1023, want: "1023 B"},
+		{bytes: 1024, want: "1.0 KB"},
+		{bytes: 1536, want: "1.5 KB"},
+		{bytes: 5 << 20, want: "5.0 MB"},
+		{bytes: 3 << 30, want: "3.0 GB"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatSize(tt.bytes); got != tt.want {
+			t.Errorf("FormatSize(%d) = %q, want %q", tt.bytes, got, tt.want)
+		}
+	}
+}
+
@@ -0,0 +1,202 @@
+//soppo:generated v1
+package report
+
+import "encoding/json"
+import "fmt"
+import "io"
+import "github.com/halcyonnouveau/sopmod/gen/internal/compat"
+import "github.com/halcyonnouveau/sopmod/gen/internal/config"
+import "github.com/halcyonnouveau/sopmod/gen/internal/doctor"
+import "github.com/halcyonnouveau/sopmod/gen/internal/install"
+import "github.com/halcyonnouveau/sopmod/gen/internal/paths"
+import "github.com/halcyonnouveau/sopmod/gen/internal/shim"
+
+// Schema is the version of the JSON documents in this package, reported in
+// each of them. It only changes when a field is removed or changes meaning;
+// new fields may appear at any time.
+const Schema = 1
+
+// Versions is the JSON form of `sopmod list`
+type Versions struct {
+	Schema int `json:"schema"`
+	Go []GoEntry `json:"go"`
+	Sop []SopEntry `json:"sop"`
+}
+
+// GoEntry describes one installed Go
+type GoEntry struct {
+	Version string `json:"version"`
+	Path string `json:"path"`
+	Default bool `json:"default"`
+}
+
+// SopEntry describes one installed sop
+type SopEntry struct {
+	Version string `json:"version"`
+	Path string `json:"path"`
+	Default bool `json:"default"`
+	Channels []string `json:"channels"`
+	Source string `json:"source"`
+	Compat *Compat `json:"compat"` //soppo:nilable
+}
+
+// Channels that currently resolve to this version
+// Linked directory or git ref for custom builds, empty for releases
+// Null when the Go requirements are unknown
+
+// Compat is the range of Go versions a sop version works with
+type Compat struct {
+	GoMin string `json:"go_min"`
+	GoMax *string `json:"go_max"` //soppo:nilable
+	GoBad []string `json:"go_bad"`
+}
+
+// Null when there is no upper bound
+// Releases in range known not to work
+
+// NewVersions builds the list report. defaultSop and defaultGo are the installed
+// versions the shims use outside projects, empty when there is none.
+func NewVersions(cfg config.Config, goVersions []string, sopVersions []string, defaultSop string, defaultGo string) Versions {
+	v := Versions{Schema: Schema, Go: []GoEntry{}, Sop: []SopEntry{}}
+
+	for _, version := range goVersions {
+		v.Go = append(v.Go, GoEntry{
+			Version: version,
+			Path: paths.GoBinary(version),
+			Default: version == defaultGo,
+		})
+	}
+
+	for _, version := range sopVersions {
+		entry := SopEntry{
+			Version: version,
+			Path: paths.SopBinary(version),
+			Default: version == defaultSop,
+			Channels: []string{},
+			Source: install.SopSource(version),
+		}
+		for _, channel := range install.SopChannels {
+			if cfg.Channels[channel] == version {
+				entry.Channels = append(entry.Channels, channel)
+			}
+		}
+		if goCompat := compat.GoCompatFor(version); goCompat != nil {
+			entry.Compat = (&Compat{GoMin: goCompat.Min, GoMax: goCompat.Max, GoBad: append([]string{}, goCompat.Bad...)})
+		}
+		v.Sop = append(v.Sop, entry)
+	}
+
+	return v
+}
+
+// Which is the JSON form of `sopmod which`
+type Which struct {
+	Schema int `json:"schema"`
+	Tool string `json:"tool"`
+	Wanted string `json:"wanted"`
+	Version string `json:"version"`
+	Path string `json:"path"`
+	Project *string `json:"project"` //soppo:nilable
+}
+
+// Version, prefix or channel as configured
+// Installed version it resolves to
+// Version file that chose the version, null outside a project
+
+// NewWhich builds the which report for sop, sopls or go
+func NewWhich(tool string, res shim.Resolution) (Which, error) {
+	w := Which{Schema: Schema, Tool: tool, Project: project(res)}
+
+	switch tool {
+	case "sop", "sopls":
+		w.Wanted = res.SopWanted
+		w.Version = res.Sop
+		w.Path = paths.SopBinary(res.Sop)
+		if tool == "sopls" {
+			w.Path = paths.SoplsBinary(res.Sop)
+		}
+	case "go":
+		if res.Go == "" {
+			return Which{}, fmt.Errorf("no go version configured. Run `sopmod default <version>`")
+		}
+		w.Wanted = res.GoWanted
+		w.Version = res.Go
+		w.Path = paths.GoBinary(res.Go)
+	default:
+		return Which{}, fmt.Errorf("unknown tool '%s'. Use 'sop', 'sopls' or 'go'", tool)
+	}
+
+	return w, nil
+}
+
+// Env is the JSON form of `sopmod env`
+type Env struct {
+	Schema int `json:"schema"`
+	Sop Tool `json:"sop"`
+	Go *Tool `json:"go"` //soppo:nilable
+	Project *string `json:"project"` //soppo:nilable
+	Path []string `json:"path"`
+}
+
+// Null when no go is configured
+// Null outside a project
+// Directories the shims put in front of PATH
+
+// Tool is a resolved tool in the env report
+type Tool struct {
+	Wanted string `json:"wanted"`
+	Version string `json:"version"`
+	Path string `json:"path"`
+}
+
+// NewEnv builds the env report
+func NewEnv(res shim.Resolution) Env {
+	e := Env{
+		Schema: Schema,
+		Sop: Tool{Wanted: res.SopWanted, Version: res.Sop, Path: paths.SopBinary(res.Sop)},
+		Project: project(res),
+		Path: []string{},
+	}
+	if res.Go != "" {
+		e.Go = (&Tool{Wanted: res.GoWanted, Version: res.Go, Path: paths.GoBinary(res.Go)})
+		e.Path = append(e.Path, res.GoBinDir())
+	}
+	return e
+}
+
+// Doctor is the JSON form of `sopmod doctor`
+type Doctor struct {
+	Schema int `json:"schema"`
+	Checks []doctor.Check `json:"checks"`
+	Failed int `json:"failed"`
+}
+
+// Checks that make the command fail
+
+// NewDoctor builds the doctor report. With strict, warnings count as failures.
+func NewDoctor(checks []doctor.Check, strict bool) Doctor {
+	d := Doctor{Schema: Schema, Checks: checks}
+	for _, check := range checks {
+		if check.Status == doctor.Fail || (strict && check.Status == doctor.Warn) {
+			d.Failed++
+		}
+	}
+	return d
+}
+
+// Write encodes a report as indented JSON
+func Write(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// project returns the resolution's version file, nil outside a project
+//soppo:nilable : 0
+func project(res shim.Resolution) *string {
+	if res.Project == "" {
+		return nil
+	}
+	return (&res.Project)
+}
+
@@ -0,0 +1,114 @@
+//soppo:generated v1
+package report
+
+import "bytes"
+import "flag"
+import "os"
+import "path/filepath"
+import "testing"
+import "github.com/halcyonnouveau/sopmod/gen/internal/config"
+import "github.com/halcyonnouveau/sopmod/gen/internal/doctor"
+import "github.com/halcyonnouveau/sopmod/gen/internal/shim"
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// checkGolden compares a report's JSON with testdata/<name>.json
+func checkGolden(t *testing.T, name string, v any) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	_err0 := Write((&buf), v)
+	if _err0 != nil {
+		err := _err0
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	path := filepath.Join("testdata", name + ".json")
+	if (*update) {
+		_err1 := os.WriteFile(path, buf.Bytes(), 0o644)
+		if _err1 != nil {
+			err := _err1
+			t.Fatalf("failed to update golden file: %v", err)
+		}
+	}
+
+	want, _err2 := os.ReadFile(path)
+	if _err2 != nil {
+		err := _err2
+		t.Fatalf("failed to read golden file: %v", err)
+	}
+	if (!bytes.Equal(buf.Bytes(), want)) {
+		t.Errorf("%s output differs from %s:\n%s", name, path, buf.String())
+	}
+}
+
+// fakeHome points sopmod at a fixed home so paths in the output are stable
+func fakeHome(t *testing.T) {
+	t.Setenv("HOME", "/home/user")
+	t.Setenv("USERPROFILE", "/home/user")
+}
+
+func TestVersionsGolden(t *testing.T) {
+	fakeHome(t)
+
+	stable := "0.5.1"
+	cfg := config.Config{DefaultSop: (&stable)}
+	cfg.SetChannel("stable", "0.5.1")
+	cfg.SetChannel("beta", "0.6.0-beta.1")
+
+	v := NewVersions(cfg, []string{"1.22.5", "1.23.4"}, []string{"0.5.1", "0.6.0-beta.1"}, "0.5.1", "1.23.4")
+	checkGolden(t, "versions", v)
+}
+
+func TestVersionsEmptyGolden(t *testing.T) {
+	fakeHome(t)
+	checkGolden(t, "versions_empty", NewVersions(config.Config{}, []string{}, []string{}, "", ""))
+}
+
+func TestWhichGolden(t *testing.T) {
+	fakeHome(t)
+	res := shim.Resolution{SopWanted: "0.5", Sop: "0.5.1", GoWanted: "1.23", Go: "1.23.4", Project: "/src/app/sop.mod"}
+
+	for _, tool := range []string{"sop", "sopls", "go"} {
+		w, _err0 := NewWhich(tool, res)
+		if _err0 != nil {
+			err := _err0
+			t.Fatalf("NewWhich(%q) failed: %v", tool, err)
+		}
+		checkGolden(t, "which_" + tool, w)
+	}
+}
+
+func TestWhichErrors(t *testing.T) {
+	res := shim.Resolution{SopWanted: "0.5.1", Sop: "0.5.1"}
+	if _, err := NewWhich("go", res); err == nil {
+		t.Error("NewWhich(go) should fail when no go is configured")
+	}
+	if _, err := NewWhich("cargo", res); err == nil {
+		t.Error("NewWhich(cargo) should fail for unknown tools")
+	}
+}
+
+func TestEnvGolden(t *testing.T) {
+	fakeHome(t)
+	checkGolden(t, "env", NewEnv(shim.Resolution{SopWanted: "stable", Sop: "0.5.1", GoWanted: "1.23", Go: "1.23.4", Project: "/src/app/sop.mod"}))
+	checkGolden(t, "env_no_go", NewEnv(shim.Resolution{SopWanted: "0.5.1", Sop: "0.5.1"}))
+}
+
+func TestDoctorGolden(t *testing.T) {
+	checks := []doctor.Check{
+		{Name: "path", Status: doctor.Pass, Message: "/home/user/.sopmod/bin is on PATH ahead of other sop binaries"},
+		{Name: "shims", Status: doctor.Warn, Message: "sop shim is a copy of a different sopmod", Hint: "sopmod shim refresh"},
+		{Name: "defaults", Status: doctor.Fail, Message: "default go 1.23 is not installed", Hint: "sopmod install go 1.23"},
+	}
+
+	d := NewDoctor(checks, false)
+	if d.Failed != 1 {
+		t.Errorf("Failed = %d, want 1", d.Failed)
+	}
+	if strict := NewDoctor(checks, true); strict.Failed != 2 {
+		t.Errorf("strict Failed = %d, want 2", strict.Failed)
+	}
+	checkGolden(t, "doctor", d)
+}
+
@@ -0,0 +1,17 @@
+//soppo:generated v1
+//go:build unix
+
+package shim
+
+import "os"
+import "syscall"
+
+// deviceOf returns the ID of the filesystem a file is on
+func deviceOf(info os.FileInfo) (uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if (!ok) {
+		return 0, false
+	}
+	return uint64(stat.Dev), true
+}
+
@@ -1,14 +1,22 @@
 //soppo:generated v1
 package shim
 
+import "bytes"
 import "fmt"
+import "io"
 import "os"
+import "os/exec"
+import "os/signal"
 import "path/filepath"
+import "runtime"
+import "slices"
 import "strings"
 import "syscall"
+import "github.com/halcyonnouveau/sopmod/gen/internal/compat"
 import "github.com/halcyonnouveau/sopmod/gen/internal/config"
 import "github.com/halcyonnouveau/sopmod/gen/internal/install"
 import "github.com/halcyonnouveau/sopmod/gen/internal/paths"
+import "github.com/halcyonnouveau/sopmod/gen/internal/ui"
 
 // Run executes the sop shim, resolving versions and setting up the environment
 func Run() error {
@@ -21,69 +29,299 @@ func RunLsp() error {
 }
 
 func runBinary(binaryPathFn func(string) string) error {
-	wantedSop, _err0 := findSopVersion()
+	res, _err0 := Resolve()
 	if _err0 != nil {
 		return _err0
 	}
-	sopVersion := ResolveInstalledVersion(wantedSop, install.ListInstalledSop())
-	if sopVersion == "" {
-		return fmt.Errorf("sop %s is not installed. Run `sopmod install sop %s`", wantedSop, wantedSop)
+	cfg := config.Load()
+	_err1 := checkCompat(cfg, res)
+	if _err1 != nil {
+		return _err1
 	}
-	binary := binaryPathFn(sopVersion)
+	binary := binaryPathFn(res.Sop)
 
 	// Set up environment with managed Go version
 	env := os.Environ()
+	if goBinDir := res.GoBinDir(); goBinDir != "" {
+		env = append(env, "PATH=" + goBinDir + string(os.PathListSeparator) + os.Getenv("PATH"))
+	}
+
+	// Remember the project so `sopmod projects` and `sopmod prune` know about it
+	if res.Project != "" && cfg.TracksProjects() {
+		config.AddProject(res.Project)
+	}
+
+	// Exec binary with all original args. Windows has no exec, so run it as a
+	// child there and pass its exit status on.
+	if runtime.GOOS != "windows" {
+		args := append([]string{binary}, os.Args[1:]...)
+		return syscall.Exec(binary, args, env)
+	}
+	code, _err2 := spawn(binary, os.Args[1:], env)
+	if _err2 != nil {
+		return _err2
+	}
+	os.Exit(code)
+	return nil
+}
+
+// checkCompat warns or fails, as compat_check says, when the go the shim
+// would run sop with doesn't suit it
+func checkCompat(cfg config.Config, res Resolution) error {
+	mode, _err0 := cfg.CompatCheckMode()
+	if _err0 != nil {
+		return _err0
+	}
+	if mode == config.CompatOff || res.Go == "" || compat.IsGoCompatible(res.Go, res.Sop) {
+		return nil
+	}
+
+	message := fmt.Sprintf("%s, but go %s is selected", compat.CompatMessage(res.Sop), res.Go)
+	hint := compatHint(res.Sop, install.ListInstalledGo())
+	if mode == config.CompatError {
+		return fmt.Errorf("%s. %s (or set compat_check = \"warn\" in config.toml)", message, hint)
+	}
+	ui.Warn("%s", message)
+	ui.Hint("%s", hint)
+	return nil
+}
+
+// compatHint points at the newest installed go that suits sopVersion, or at
+// installing the oldest one that does
+func compatHint(sopVersion string, installedGo []string) string {
+	if best := BestCompatibleGo(sopVersion, installedGo); best != "" {
+		return fmt.Sprintf("go %s is installed and works with sop %s, pin it with go = \"%s\" in sop.mod", best, sopVersion, best)
+	}
+	if goCompat := compat.GoCompatFor(sopVersion); goCompat != nil {
+		return fmt.Sprintf("run `sopmod install go %s` and pin it with go = \"%s\" in sop.mod", goCompat.Min, goCompat.Min)
+	}
+	return "pin a newer go in sop.mod"
+}
+
+// BestCompatibleGo returns the newest installed go that works with sopVersion
+func BestCompatibleGo(sopVersion string, installedGo []string) string {
+	var best string
+	for _, v := range installedGo {
+		if compat.IsGoCompatible(v, sopVersion) {
+			if best == "" || compat.CompareVersions(v, best) > 0 {
+				best = v
+			}
+		}
+	}
+	return best
+}
+
+// Resolution is what the shims run in the current directory
+type Resolution struct {
+	SopWanted string
+	Sop string
+	GoWanted string
+	Go string
+	Project string
+	Local string
+}
+
+// Version, prefix or channel as configured
+// Installed sop version SopWanted resolves to
+// Empty when no go is configured
+// Installed go version GoWanted resolves to
+// Nearest version file, empty outside a project
+// Directory whose `sopmod local` override applies, if any
+
+// Resolve works out which installed sop and go the shims run in the current directory
+func Resolve() (Resolution, error) {
+	wantedSop, _err0 := findSopVersion()
+	if _err0 != nil {
+		return Resolution{}, _err0
+	}
+	res := Resolution{SopWanted: wantedSop}
+
+	// Channel pins follow whatever the channel was last installed as
+	if install.IsSopChannel(wantedSop) {
+		channel := wantedSop
+		cfg := config.Load()
+		pinned, ok := cfg.Channels[channel]
+		if (!ok) {
+			return Resolution{}, fmt.Errorf("no sop installed from the %s channel. Run `sopmod install sop %s`", channel, channel)
+		}
+		wantedSop = pinned
+	}
+	res.Sop = ResolveInstalledVersion(wantedSop, install.ListInstalledSop())
+	if res.Sop == "" {
+		return Resolution{}, fmt.Errorf("sop %s is not installed. Run `sopmod install sop %s`", wantedSop, wantedSop)
+	}
+
 	if wantedGo, err := findGoVersion(); err == nil && wantedGo != "" {
-		goVersion := ResolveInstalledVersion(wantedGo, install.ListInstalledGo())
-		if goVersion == "" {
-			return fmt.Errorf("go %s is not installed. Run `sopmod install go %s`", wantedGo, wantedGo)
+		res.GoWanted = wantedGo
+		res.Go = ResolveInstalledGo(wantedGo, install.ListInstalledGo())
+		if res.Go == "" {
+			return Resolution{}, fmt.Errorf("go %s is not installed. Run `sopmod install go %s`", wantedGo, wantedGo)
 		}
-		goBinDir := filepath.Dir(paths.GoBinary(goVersion))
-		env = append(env, "PATH=" + goBinDir + ":" + os.Getenv("PATH"))
 	}
 
-	// Exec binary with all original args
-	args := append([]string{binary}, os.Args[1:]...)
-	return syscall.Exec(binary, args, env)
+	if lookup, err := findProject(); err == nil {
+		res.Project = lookup.path
+		res.Local = lookup.local
+	}
+	return res, nil
+}
+
+// GoBinDir returns the directory the shims put first on PATH, or "" when no go is configured
+func (r Resolution) GoBinDir() string {
+	if r.Go == "" {
+		return ""
+	}
+	return filepath.Dir(paths.GoBinary(r.Go))
 }
 
-// Install copies the current binary to both sop and sopls shim locations
-func Install() error {
+// Name returns the shim a program name invokes, "sop" or "sopls", or "" when
+// sopmod was run under any other name. Only the exact base name counts, with an
+// optional .exe suffix.
+//
+// ```sop
+// import "fmt"
+// fmt.Println(Name("/home/user/.sopmod/bin/sop"))
+// fmt.Println(Name("sopls.exe"))
+// fmt.Printf("%q\n", Name("mysop"))
+// // Output:
+// // sop
+// // sopls
+// // ""
+// ```
+func Name(arg0 string) string {
+	base := filepath.Base(arg0)
+	if ext := filepath.Ext(base); strings.EqualFold(ext, ".exe") {
+		base = strings.TrimSuffix(base, ext)
+	}
+	// Windows file names are case-insensitive
+	if runtime.GOOS == "windows" {
+		base = strings.ToLower(base)
+	}
+
+	switch base {
+	case "sop", "sopls":
+		return base
+	default:
+		return ""
+	}
+}
+
+// spawn runs binary as a child process and returns the status it exited with.
+// It's how the shim runs sop on Windows, which has no exec.
+func spawn(binary string, args []string, env []string) (int, error) {
+	cmd := exec.Command(binary, args...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// Ctrl+C reaches every process on the console, so sop gets it directly
+	// and decides what it means. The shim only catches it to outlive sop and
+	// pass on how it exited.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+
+	_err0 := cmd.Start()
+	if _err0 != nil {
+		return 0, _err0
+	}
+
+	err := cmd.Wait()
+	if err != nil && cmd.ProcessState == nil {
+		return 0, err
+	}
+	return cmd.ProcessState.ExitCode(), nil
+}
+
+// Install links the running binary into both sop and sopls shim locations
+func Install(version string) error {
 	currentExe, _err0 := os.Executable()
 	if _err0 != nil {
 		return _err0
 	}
+	return InstallFrom(currentExe, version)
+}
 
-	// Install sop shim
-	sopShimPath := paths.SopShim()
-	os.Remove(sopShimPath)
-	_err1 := copyFile(currentExe, sopShimPath)
-	if _err1 != nil {
-		return _err1
+// InstallFrom links both shim locations to the sopmod binary at exe, built as version.
+// Symlinks are preferred so upgrading sopmod upgrades the shims with it, then
+// hardlinks, then plain copies where neither is available.
+func InstallFrom(exe string, version string) error {
+	target, _err0 := filepath.EvalSymlinks(exe)
+	if _err0 != nil {
+		return _err0
 	}
-	_err2 := os.Chmod(sopShimPath, 0o755)
-	if _err2 != nil {
-		return _err2
+	for _, shimPath := range []string{paths.SopShim(), paths.SoplsShim()} {
+		_err1 := installShim(target, shimPath)
+		if _err1 != nil {
+			return _err1
+		}
 	}
+	return os.WriteFile(paths.ShimVersionFile(), []byte(version + "\n"), 0o644)
+}
 
-	// Install sopls shim
-	soplsShimPath := paths.SoplsShim()
-	os.Remove(soplsShimPath)
-	_err3 := copyFile(currentExe, soplsShimPath)
-	if _err3 != nil {
-		return _err3
+func installShim(target string, shimPath string) error {
+	os.Remove(shimPath)
+	if runtime.GOOS != "windows" {
+		if err := os.Symlink(target, shimPath); err == nil {
+			return nil
+		}
 	}
-	return os.Chmod(soplsShimPath, 0o755)
+	if err := os.Link(target, shimPath); err == nil {
+		return nil
+	}
+	_err0 := copyFile(target, shimPath)
+	if _err0 != nil {
+		return _err0
+	}
+	return os.Chmod(shimPath, 0o755)
+}
+
+// IsStale reports whether the shim at path is a hardlink or copy of a different
+// sopmod than version. Symlinked shims run whatever they point at, so they're
+// never stale.
+func IsStale(path string, version string) bool {
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSymlink != 0 {
+		return false
+	}
+
+	data, err := os.ReadFile(paths.ShimVersionFile())
+	if err != nil {
+		// Shims from before the version was recorded, compare the binaries instead
+		current, err := IsCurrent(path)
+		return err == nil && (!current)
+	}
+	return strings.TrimSpace(string(data)) != version
 }
 
 func findSopVersion() (string, error) {
-	// Check sop.mod in current dir and parents
-	if projectCfg, err := findProjectConfig(); err == nil && projectCfg != nil && projectCfg.Sop != nil {
-		return (*projectCfg.Sop), nil
+	cfg := config.Load()
+	projectCfg, _err0 := findProjectConfig()
+	if _err0 != nil {
+		return "", _err0
+	}
+
+	var projectPin *string
+	if projectCfg != nil {
+		projectPin = projectCfg.Sop
+	}
+
+	// Toolchains pin sop and go together
+	tc, _err1 := toolchainFor(cfg, projectCfg, projectPin)
+	if _err1 != nil {
+		return "", _err1
+	}
+	if tc != nil {
+		return tc.Sop, nil
+	}
+
+	// Check sopmod local and the version file in current dir and parents
+	if projectPin != nil {
+		return (*projectPin), nil
 	}
 
 	// Fall back to default
-	cfg := config.Load()
 	if cfg.DefaultSop != nil {
 		return (*cfg.DefaultSop), nil
 	}
@@ -92,13 +330,32 @@ func findSopVersion() (string, error) {
 }
 
 func findGoVersion() (string, error) {
-	// Check sop.mod in current dir and parents
-	if projectCfg, err := findProjectConfig(); err == nil && projectCfg != nil && projectCfg.Go != nil {
-		return (*projectCfg.Go), nil
+	cfg := config.Load()
+	projectCfg, _err0 := findProjectConfig()
+	if _err0 != nil {
+		return "", _err0
+	}
+
+	var projectPin *string
+	if projectCfg != nil {
+		projectPin = projectCfg.Go
+	}
+
+	// Toolchains pin sop and go together
+	tc, _err1 := toolchainFor(cfg, projectCfg, projectPin)
+	if _err1 != nil {
+		return "", _err1
+	}
+	if tc != nil {
+		return tc.Go, nil
+	}
+
+	// Check sopmod local and the version file in current dir and parents
+	if projectPin != nil {
+		return (*projectPin), nil
 	}
 
 	// Fall back to default
-	cfg := config.Load()
 	if cfg.DefaultGo != nil {
 		return (*cfg.DefaultGo), nil
 	}
@@ -106,32 +363,239 @@ func findGoVersion() (string, error) {
 	return "", nil
 }
 
-// findProjectConfig walks up the directory tree looking for sop.mod
+// toolchainFor returns the toolchain that decides a tool's version, if any.
+// SOPMOD_TOOLCHAIN beats everything, then an explicit sop.mod pin for the tool,
+// then a toolchain named in sop.mod, then the default toolchain.
+//soppo:nilable : 0
+func toolchainFor(cfg config.Config, projectCfg *config.ProjectConfig, projectPin *string) (*config.Toolchain, error) {
+	name := os.Getenv("SOPMOD_TOOLCHAIN")
+	if name == "" {
+		if projectPin != nil {
+			return nil, nil
+		}
+		if projectCfg != nil && projectCfg.Toolchain != nil {
+			name = (*projectCfg.Toolchain)
+		} else {
+			if cfg.DefaultToolchain != nil {
+				name = (*cfg.DefaultToolchain)
+			} else {
+				return nil, nil
+			}
+		}
+	}
+
+	tc, _err0 := cfg.FindToolchain(name)
+	if _err0 != nil {
+		return nil, _err0
+	}
+	return (&tc), nil
+}
+
+// projectLookup is a finished project search, kept for the rest of the
+// invocation since resolving sop and go both need it
+type projectLookup struct {
+	dir string
+	path string
+	local string
+	cfg *config.ProjectConfig //soppo:nilable
+}
+// dir: Working directory the search started from
+// path: Version file found, empty outside a project
+// local: Directory of the `sopmod local` override in effect, if any
+
+var lastProject *projectLookup //soppo:nilable
+
+// findProjectConfig loads the nearest version file, if there is one. A file
+// that doesn't parse or pins an invalid version is an error rather than
+// ignored, so the project never silently runs with the default.
 //soppo:nilable : 0
 func findProjectConfig() (*config.ProjectConfig, error) {
-	current, _err0 := os.Getwd()
+	lookup, _err0 := findProject()
+	if _err0 != nil {
+		return nil, _err0
+	}
+	return lookup.cfg, nil
+}
+
+// findProject searches for and loads the project once per working directory.
+// A `sopmod local` override beats the version file for the tools it sets.
+func findProject() (*projectLookup, error) {
+	cwd, _err0 := os.Getwd()
 	if _err0 != nil {
 		return nil, _err0
 	}
+	if lastProject != nil && lastProject.dir == cwd {
+		return lastProject, nil
+	}
+
+	lookup := (&projectLookup{dir: cwd, path: findVersionFile(cwd, ceilingDirs(os.Getenv("SOPMOD_CEILING_DIRECTORIES")))})
+	if lookup.path != "" {
+		var _err1 error
+		lookup.cfg, _err1 = config.LoadPins(lookup.path)
+		if _err1 != nil {
+			err := _err1
+			return nil, fmt.Errorf("%s: %w", lookup.path, err)
+		}
+	}
+
+	overrides, _err2 := config.LoadOverrides()
+	if _err2 != nil {
+		return nil, _err2
+	}
+	if dir, local := overrides.For(cwd); local != nil {
+		merged := config.ProjectConfig{}
+		if lookup.cfg != nil {
+			merged = (*lookup.cfg)
+		}
+		if local.Sop != nil {
+			merged.Sop = local.Sop
+		}
+		if local.Go != nil {
+			merged.Go = local.Go
+		}
+		lookup.cfg = (&merged)
+		lookup.local = dir
+	}
+	lastProject = lookup
+	return lookup, nil
+}
+
+// FindProjectFile walks up from the working directory looking for a version
+// file: sop.mod, .sop-version or .tool-versions, in that order within a
+// directory. Returns an empty path when no project is found.
+func FindProjectFile() (string, error) {
+	cwd, _err0 := os.Getwd()
+	if _err0 != nil {
+		return "", _err0
+	}
+	return findVersionFile(cwd, ceilingDirs(os.Getenv("SOPMOD_CEILING_DIRECTORIES"))), nil
+}
+
+// findVersionFile walks up from start. The walk stops after a repository root
+// (.git or .hg), before entering a ceiling directory and at a mount boundary,
+// so a stray sop.mod in $HOME or /tmp doesn't pin every project below it.
+func findVersionFile(start string, ceilings []string) string {
+	current := start
+	for {
+		for _, name := range config.VersionFiles {
+			path := filepath.Join(current, name)
+			if fileExists(path) {
+				return path
+			}
+		}
+
+		parent, ok := walkParent(current, ceilings)
+		if (!ok) {
+			return ""
+		}
+		current = parent
+	}
+}
 
+// FindWorkspace walks up from the working directory, within the same
+// boundaries as FindProjectFile, for a sop.mod with a [workspace] table.
+// Returns nil outside a workspace.
+//soppo:nilable : 0
+func FindWorkspace() (*config.ProjectFile, error) {
+	cwd, _err0 := os.Getwd()
+	if _err0 != nil {
+		return nil, _err0
+	}
+	ceilings := ceilingDirs(os.Getenv("SOPMOD_CEILING_DIRECTORIES"))
+	current := cwd
 	for {
-		sopModPath := filepath.Join(current, "sop.mod")
-		if fileExists(sopModPath) {
-			return config.LoadProjectConfig(current)
+		path := filepath.Join(current, "sop.mod")
+		if fileExists(path) {
+			project, _err1 := config.LoadProjectFile(path)
+			if _err1 != nil {
+				err := _err1
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			if project.Workspace != nil {
+				return project, nil
+			}
 		}
 
-		parent := filepath.Dir(current)
-		if parent == current {
-			break
+		parent, ok := walkParent(current, ceilings)
+		if (!ok) {
+			return nil, nil
 		}
 		current = parent
 	}
+}
+
+// walkParent returns the directory a project search moves on to after dir,
+// or false when dir is the last one it looks in
+func walkParent(dir string, ceilings []string) (string, bool) {
+	if isRepoRoot(dir) {
+		return "", false
+	}
+	parent := filepath.Dir(dir)
+	if parent == dir || slices.Contains(ceilings, parent) || crossesMount(dir, parent) {
+		return "", false
+	}
+	return parent, true
+}
+
+// ceilingDirs parses SOPMOD_CEILING_DIRECTORIES, a PATH-style list. Relative
+// entries are ignored, as git does for GIT_CEILING_DIRECTORIES.
+func ceilingDirs(value string) []string {
+	dirs := []string{}
+	for _, dir := range filepath.SplitList(value) {
+		if filepath.IsAbs(dir) {
+			dirs = append(dirs, filepath.Clean(dir))
+		}
+	}
+	return dirs
+}
+
+// isRepoRoot reports whether dir is the top of a git or mercurial checkout.
+// .git can be a file in worktrees and submodules.
+func isRepoRoot(dir string) bool {
+	for _, marker := range []string{".git", ".hg"} {
+		if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// crossesMount reports whether parent is on a different filesystem than dir
+func crossesMount(dir string, parent string) bool {
+	dirInfo, _err0 := os.Stat(dir)
+	if _err0 != nil {
+		return false
+	}
+	parentInfo, _err1 := os.Stat(parent)
+	if _err1 != nil {
+		return false
+	}
+	dirDevice, dirOk := deviceOf(dirInfo)
+	parentDevice, parentOk := deviceOf(parentInfo)
+	return dirOk && parentOk && dirDevice != parentDevice
+}
 
-	return nil, nil
+// IsCurrent reports whether the shim at path is a copy of the running sopmod binary
+func IsCurrent(path string) (bool, error) {
+	exe, _err0 := os.Executable()
+	if _err0 != nil {
+		return false, _err0
+	}
+	want, _err1 := os.ReadFile(exe)
+	if _err1 != nil {
+		return false, _err1
+	}
+	got, _err2 := os.ReadFile(path)
+	if _err2 != nil {
+		return false, _err2
+	}
+	return bytes.Equal(want, got), nil
 }
 
 // ResolveInstalledVersion finds the best installed version matching a version or prefix.
-// Returns exact match if found, otherwise highest version matching the prefix.
+// Returns the highest installed version that is either the wanted version itself or
+// one of its patch releases, so "1.20" picks 1.20.3 over the bare go1.20 release.
+// Prereleases such as 1.23rc1 only match when asked for explicitly.
 //
 // ```sop
 // import "fmt"
@@ -139,24 +603,20 @@ func findProjectConfig() (*config.ProjectConfig, error) {
 // fmt.Println(ResolveInstalledVersion("1.22.0", []string{"1.21.0", "1.22.0", "1.23.0"}))
 // // Prefix match - returns highest
 // fmt.Println(ResolveInstalledVersion("1.22", []string{"1.22.0", "1.22.5", "1.23.0"}))
+// // Pre-1.21 release names have no patch component
+// fmt.Println(ResolveInstalledVersion("1.20", []string{"1.20", "1.20.3", "1.21rc1"}))
 // // Output:
 // // 1.22.0
 // // 1.22.5
+// // 1.20.3
 // ```
 func ResolveInstalledVersion(wanted string, installed []string) string {
-	// Exact match first
-	for _, v := range installed {
-		if v == wanted {
-			return v
-		}
-	}
-
-	// Prefix match - find highest matching version
+	// Exact or prefix match - find highest matching version
 	prefix := wanted + "."
 	var best string
 	for _, v := range installed {
-		if strings.HasPrefix(v, prefix) {
-			if best == "" || CompareVersions(v, best) > 0 {
+		if v == wanted || strings.HasPrefix(v, prefix) {
+			if best == "" || compat.CompareVersions(v, best) > 0 {
 				best = v
 			}
 		}
@@ -165,32 +625,49 @@ func ResolveInstalledVersion(wanted string, installed []string) string {
 	return best
 }
 
-// CompareVersions compares two semver strings.
-// Returns negative if a < b, zero if a == b, positive if a > b.
+// ResolveInstalledGo is ResolveInstalledVersion for go. It compares parsed
+// versions rather than names, so a "1.20.0" pin finds the go1.20 release,
+// which is installed as 1.20. A version without a patch still picks the
+// newest patch release of that line.
 //
 // ```sop
 // import "fmt"
-// fmt.Println(CompareVersions("1.22.0", "1.21.0") > 0)
-// fmt.Println(CompareVersions("1.21.0", "1.22.0") < 0)
-// fmt.Println(CompareVersions("1.22.0", "1.22.0") == 0)
+// fmt.Println(ResolveInstalledGo("1.20.0", []string{"1.20", "1.20.3"}))
+// fmt.Println(ResolveInstalledGo("1.20", []string{"1.20", "1.20.3", "1.21rc1"}))
+// fmt.Println(ResolveInstalledGo("1.21rc1", []string{"1.21rc1", "1.21.0"}))
 // // Output:
-// // true
-// // true
-// // true
+// // 1.20
+// // 1.20.3
+// // 1.21rc1
 // ```
-func CompareVersions(a string, b string) int {
-	aParts := strings.Split(a, ".")
-	bParts := strings.Split(b, ".")
+func ResolveInstalledGo(wanted string, installed []string) string {
+	want, _err0 := compat.ParseGoVersion(wanted)
+	if _err0 != nil {
+		return ResolveInstalledVersion(wanted, installed)
+	}
 
-	for i := 0; i < len(aParts) && i < len(bParts); i++ {
-		var aNum, bNum int
-		fmt.Sscanf(aParts[i], "%d", (&aNum))
-		fmt.Sscanf(bParts[i], "%d", (&bNum))
-		if aNum != bNum {
-			return aNum - bNum
+	var best string
+	var bestVersion compat.GoVersion
+	for _, name := range installed {
+		v, err := compat.ParseGoVersion(name)
+		if err != nil || (!goMatches(want, v)) {
+			continue
+		}
+		if best == "" || v.Compare(bestVersion) > 0 {
+			best = name
+			bestVersion = v
 		}
 	}
-	return len(aParts) - len(bParts)
+	return best
+}
+
+// goMatches reports whether an installed go is the wanted release or, when
+// want has no patch, a final release on that line
+func goMatches(want compat.GoVersion, v compat.GoVersion) bool {
+	if want.IsPrefix() {
+		return (!v.IsPrerelease()) && v.Major == want.Major && v.Minor == want.Minor
+	}
+	return v.Compare(want) == 0
 }
 
 func fileExists(path string) bool {
@@ -199,10 +676,19 @@ func fileExists(path string) bool {
 }
 
 func copyFile(src string, dst string) error {
-	data, _err0 := os.ReadFile(src)
+	in, _err0 := os.Open(src)
 	if _err0 != nil {
 		return _err0
 	}
-	return os.WriteFile(dst, data, 0o755)
+	defer in.Close()
+
+	out, _err1 := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o755)
+	if _err1 != nil {
+		return _err1
+	}
+	defer out.Close()
+
+	_, err := io.Copy(out, in)
+	return err
 }
 
@@ -0,0 +1,295 @@
+//soppo:generated v1
+package shim
+
+import "os"
+import "path/filepath"
+import "runtime"
+import "slices"
+import "strconv"
+import "strings"
+import "testing"
+import "time"
+import "github.com/halcyonnouveau/sopmod/gen/internal/config"
+
+func TestName(t *testing.T) {
+	tests := []struct {
+		arg0 string
+		want string
+	}{
+		{arg0: "sop", want: "sop"},
+		{arg0: "sopls", want: "sopls"},
+		{arg0: "/home/user/.sopmod/bin/sop", want: "sop"},
+		{arg0: "/home/user/.sopmod/bin/sopls", want: "sopls"},
+		{arg0: "sop.exe", want: "sop"},
+		{arg0: "sopls.exe", want: "sopls"},
+		{arg0: "sop.EXE", want: "sop"},
+		{arg0: "sopmod", want: ""},
+		{arg0: "sopmod.exe", want: ""},
+		{arg0: "/usr/local/bin/sopmod", want: ""},
+		{arg0: "mysop", want: ""},
+		{arg0: "sop-0.5", want: ""},
+		{arg0: "sopls2", want: ""},
+		{arg0: "sop.sh", want: ""},
+	}
+
+	for _, tt := range tests {
+		got := Name(tt.arg0)
+		if got != tt.want {
+			t.Errorf("Name(%q) = %q, want %q", tt.arg0, got, tt.want)
+		}
+	}
+}
+
+func TestSpawnExitCode(t *testing.T) {
+	for _, want := range []int{0, 3, 255} {
+		got, err := spawn(os.Args[0], []string{"-test.run=^TestSpawnChild$"}, childEnv(want, 0))
+		if err != nil {
+			t.Errorf("spawn(exit %d) unexpected error: %v", want, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("spawn(exit %d) = %d", want, got)
+		}
+	}
+}
+
+func TestSpawnOutlivesInterrupt(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Ctrl+C can't be sent to one process on Windows; the console sends it to sop too")
+	}
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		self, _err0 := os.FindProcess(os.Getpid())
+		if _err0 != nil {
+			return
+		}
+		self.Signal(os.Interrupt)
+	}()
+	got, err := spawn(os.Args[0], []string{"-test.run=^TestSpawnChild$"}, childEnv(7, time.Second))
+	if err != nil || got != 7 {
+		t.Errorf("spawn across an interrupt = %d, %v, want the child's 7", got, err)
+	}
+}
+
+// TestSpawnChild is the child the spawn tests run, this test binary again,
+// exiting as childEnv says
+func TestSpawnChild(t *testing.T) {
+	code := os.Getenv("SOPMOD_TEST_EXIT")
+	if code == "" {
+		return
+	}
+	if wait := os.Getenv("SOPMOD_TEST_WAIT"); wait != "" {
+		d, _ := time.ParseDuration(wait)
+		time.Sleep(d)
+	}
+	n, _ := strconv.Atoi(code)
+	os.Exit(n)
+}
+
+// childEnv has TestSpawnChild wait for wait and exit with code
+func childEnv(code int, wait time.Duration) []string {
+	return append(os.Environ(), "SOPMOD_TEST_EXIT=" + strconv.Itoa(code), "SOPMOD_TEST_WAIT=" + wait.String())
+}
+
+func TestSpawnMissingBinary(t *testing.T) {
+	_, err := spawn("/nonexistent/sop", []string{}, os.Environ())
+	if err == nil {
+		t.Error("spawn should fail when the binary doesn't exist")
+	}
+}
+
+func TestCheckCompat(t *testing.T) {
+	isolateHome(t)
+	mode := func(m string) config.Config {
+		return config.Config{CompatCheck: (&m)}
+	}
+	old := Resolution{Sop: "0.5.0", Go: "1.20.5"}
+	ok := Resolution{Sop: "0.5.0", Go: "1.23.4"}
+	noGo := Resolution{Sop: "0.5.0"}
+
+	tests := []struct {
+		cfg     config.Config
+		res     Resolution
+		wantErr bool
+	}{
+		{cfg: config.Config{}, res: old, wantErr: false},
+		{cfg: mode(config.CompatWarn), res: old, wantErr: false},
+		{cfg: mode(config.CompatOff), res: old, wantErr: false},
+		{cfg: mode(config.CompatError), res: old, wantErr: true},
+		{cfg: mode(config.CompatError), res: ok, wantErr: false},
+		{cfg: mode(config.CompatError), res: noGo, wantErr: false},
+		{cfg: mode("strict"), res: ok, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		err := checkCompat(tt.cfg, tt.res)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("checkCompat(%v, go %s) error = %v, wantErr %v", tt.cfg.CompatCheck, tt.res.Go, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCompatHint(t *testing.T) {
+	isolateHome(t)
+	tests := []struct {
+		installed []string
+		want      string
+	}{
+		{installed: []string{"1.20.5", "1.22.1", "1.23.4"}, want: "go 1.23.4 is installed"},
+		{installed: []string{"1.20.5"}, want: "sopmod install go 1.21"},
+		{installed: nil, want: "sopmod install go 1.21"},
+	}
+
+	for _, tt := range tests {
+		got := compatHint("0.5.0", tt.installed)
+		if (!strings.Contains(got, tt.want)) {
+			t.Errorf("compatHint(0.5.0, %v) = %q, want it to mention %q", tt.installed, got, tt.want)
+		}
+	}
+}
+
+// isolateHome points HOME at an empty directory, so the compat manifest is
+// the built-in one and no go is installed, whatever the real ~/.sopmod holds.
+// The manifest loads once per test binary, so this has to run before
+// anything reads it.
+func isolateHome(t *testing.T) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+}
+
+func TestResolveInstalledGo(t *testing.T) {
+	tests := []struct {
+		wanted    string
+		installed []string
+		want      string
+	}{
+		{wanted: "1.20.0", installed: []string{"1.20", "1.20.3"}, want: "1.20"},
+		{wanted: "1.20", installed: []string{"1.20", "1.20.3", "1.21rc1"}, want: "1.20.3"},
+		{wanted: "1.21", installed: []string{"1.21rc1", "1.21.0"}, want: "1.21.0"},
+		{wanted: "1.21rc1", installed: []string{"1.21rc1", "1.21.0"}, want: "1.21rc1"},
+		{wanted: "1.22.1", installed: []string{"1.22.10"}, want: ""},
+		{wanted: "1.23", installed: []string{"1.22.8"}, want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := ResolveInstalledGo(tt.wanted, tt.installed); got != tt.want {
+			t.Errorf("ResolveInstalledGo(%q, %v) = %q, want %q", tt.wanted, tt.installed, got, tt.want)
+		}
+	}
+}
+
+func TestFindProjectFile(t *testing.T) {
+	root, _err0 := filepath.EvalSymlinks(t.TempDir())
+	if _err0 != nil {
+		err := _err0
+		t.Fatalf("EvalSymlinks failed: %v", err)
+	}
+	nested := filepath.Join(root, "app", "cmd")
+	_err1 := os.MkdirAll(nested, 0o755)
+	if _err1 != nil {
+		err := _err1
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	for _, file := range []string{".tool-versions", "app/.sop-version", "app/sop.mod"} {
+		_err2 := os.WriteFile(filepath.Join(root, file), []byte(""), 0o644)
+		if _err2 != nil {
+			err := _err2
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		dir  string
+		want string
+	}{
+		{dir: nested, want: filepath.Join(root, "app", "sop.mod")},
+		{dir: root, want: filepath.Join(root, ".tool-versions")},
+	}
+
+	for _, tt := range tests {
+		t.Chdir(tt.dir)
+		got, _err3 := FindProjectFile()
+		if _err3 != nil {
+			err := _err3
+			t.Fatalf("FindProjectFile failed: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("FindProjectFile() in %s = %s, want %s", tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestFindVersionFileBoundaries(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"repo/.git", "repo/pkg", "other/pkg"} {
+		_err0 := os.MkdirAll(filepath.Join(root, dir), 0o755)
+		if _err0 != nil {
+			err := _err0
+			t.Fatalf("MkdirAll failed: %v", err)
+		}
+	}
+	// A stray sop.mod above both projects
+	_err1 := os.WriteFile(filepath.Join(root, "sop.mod"), []byte("sop = \"0.4\"\n"), 0o644)
+	if _err1 != nil {
+		err := _err1
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	tests := []struct {
+		start    string
+		ceilings []string
+		want     string
+	}{
+		{start: filepath.Join(root, "repo", "pkg"), ceilings: nil, want: ""},
+		{start: filepath.Join(root, "other", "pkg"), ceilings: nil, want: filepath.Join(root, "sop.mod")},
+		{start: filepath.Join(root, "other", "pkg"), ceilings: []string{root}, want: ""},
+		{start: filepath.Join(root, "other", "pkg"), ceilings: []string{filepath.Join(root, "other", "pkg")}, want: filepath.Join(root, "sop.mod")},
+	}
+
+	for _, tt := range tests {
+		got := findVersionFile(tt.start, tt.ceilings)
+		if got != tt.want {
+			t.Errorf("findVersionFile(%s, %v) = %q, want %q", tt.start, tt.ceilings, got, tt.want)
+		}
+	}
+}
+
+func TestDeviceOf(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub")
+	_err0 := os.Mkdir(sub, 0o755)
+	if _err0 != nil {
+		err := _err0
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	info, _err1 := os.Stat(sub)
+	if _err1 != nil {
+		err := _err1
+		t.Fatalf("Stat failed: %v", err)
+	}
+
+	_, ok := deviceOf(info)
+	if want := runtime.GOOS != "windows"; ok != want {
+		t.Errorf("deviceOf(%s) ok = %v, want %v", sub, ok, want)
+	}
+	if crossesMount(sub, dir) {
+		t.Errorf("crossesMount(%s, %s) = true for a directory and its parent on one filesystem", sub, dir)
+	}
+}
+
+func TestCeilingDirs(t *testing.T) {
+	value := strings.Join([]string{"/home/me/", "relative", "", "/srv"}, string(os.PathListSeparator))
+	got := ceilingDirs(value)
+	want := []string{filepath.Clean("/home/me/"), filepath.Clean("/srv")}
+	if runtime.GOOS == "windows" {
+		// Neither is absolute without a drive letter
+		want = []string{}
+	}
+	if (!slices.Equal(got, want)) {
+		t.Errorf("ceilingDirs(%q) = %v, want %v", value, got, want)
+	}
+}
+
@@ -0,0 +1,224 @@
+//soppo:generated v1
+package ui
+
+import "bufio"
+import "errors"
+import "fmt"
+import "io"
+import "os"
+import "strings"
+import "sync"
+
+// Colour modes accepted by --color
+const (
+	Auto   = "auto"
+	Always = "always"
+	Never  = "never"
+)
+
+var colorMode = Auto
+
+// assume answers prompts without asking: "yes", "no" or empty to ask
+var assume = ""
+
+// SetColor sets the colour mode from a --color value
+func SetColor(mode string) error {
+	switch mode {
+	case Auto, Always, Never:
+		colorMode = mode
+		return nil
+	}
+	return fmt.Errorf("unknown color mode '%s'. Use 'auto', 'always' or 'never'", mode)
+}
+
+// Stream is an output that decides on colour for itself, so piping stdout
+// doesn't strip colour from progress on a terminal's stderr and vice versa.
+// Writes are serialised, so lines from concurrent installs never interleave.
+type Stream struct {
+	file *os.File
+	mu sync.Mutex
+}
+
+// Out carries results: lists, paths and JSON
+var Out = (&Stream{file: os.Stdout})
+
+// Err carries progress, prompts, warnings and errors
+var Err = (&Stream{file: os.Stderr})
+
+// Color reports whether styles written to the stream are coloured
+func (s *Stream) Color() bool {
+	return colorEnabled(colorMode, os.Getenv("NO_COLOR"), os.Getenv("CLICOLOR_FORCE"), IsTerminal(s.file))
+}
+
+// Bold styles text for the stream
+func (s *Stream) Bold(text string) string {
+	return s.style("1", text)
+}
+
+// Dim styles text for the stream
+func (s *Stream) Dim(text string) string {
+	return s.style("2", text)
+}
+
+// Green styles text for the stream
+func (s *Stream) Green(text string) string {
+	return s.style("32", text)
+}
+
+// Yellow styles text for the stream
+func (s *Stream) Yellow(text string) string {
+	return s.style("33", text)
+}
+
+// Red styles text for the stream
+func (s *Stream) Red(text string) string {
+	return s.style("31", text)
+}
+
+// Cyan styles text for the stream
+func (s *Stream) Cyan(text string) string {
+	return s.style("36", text)
+}
+
+func (s *Stream) style(code string, text string) string {
+	if (!s.Color()) {
+		return text
+	}
+	return "\033[" + code + "m" + text + "\033[0m"
+}
+
+// Printf writes formatted text to the stream
+func (s *Stream) Printf(format string, args ...any) {
+	s.Write([]byte(fmt.Sprintf(format, args...)))
+}
+
+// Println writes a line to the stream
+func (s *Stream) Println(args ...any) {
+	s.Write([]byte(fmt.Sprintln(args...)))
+}
+
+// Write lets the stream stand in for an io.Writer, e.g. a child's output
+func (s *Stream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Write(p)
+}
+
+// Success reports a finished step on stderr
+func Success(format string, args ...any) {
+	Err.Printf("%s %s\n", Err.Green("✓"), fmt.Sprintf(format, args...))
+}
+
+// Step announces something sopmod is about to do on its own accord
+func Step(format string, args ...any) {
+	Err.Printf("%s %s\n", Err.Cyan("→"), fmt.Sprintf(format, args...))
+}
+
+// Progress reports work in progress on stderr
+func Progress(format string, args ...any) {
+	Err.Printf(format+"\n", args...)
+}
+
+// Warn prints a warning on stderr
+func Warn(format string, args ...any) {
+	Err.Printf("%s %s\n", Err.Yellow("warning:"), fmt.Sprintf(format, args...))
+}
+
+// Hint prints an indented hint on stderr, usually after a warning or error
+func Hint(format string, args ...any) {
+	Err.Printf("  %s %s\n", Err.Cyan("hint:"), fmt.Sprintf(format, args...))
+}
+
+// Error prints an error on stderr
+func Error(err error) {
+	Err.Printf("%s %s\n", Err.style("31;1", "error:"), err)
+}
+
+// AssumeYes answers every prompt with yes, for --yes
+func AssumeYes() {
+	assume = "yes"
+}
+
+// AssumeNo answers every prompt with no, for --no
+func AssumeNo() {
+	assume = "no"
+}
+
+// Interactive reports whether prompts can wait for an answer: stdin is a
+// terminal and CI isn't set
+func Interactive() bool {
+	return interactive(IsTerminal(os.Stdin), os.Getenv("CI"))
+}
+
+// Confirm asks a yes/no question on stderr, returning def for an empty reply.
+// --yes and --no answer without asking. When nobody can answer, def is used,
+// and the answer is printed either way so logs show what was decided.
+func Confirm(question string, def bool) (bool, error) {
+	choices := "[y/N]"
+	if def {
+		choices = "[Y/n]"
+	}
+	Err.Printf("%s %s ", question, choices)
+
+	if assume != "" {
+		Err.Println(assume)
+		return assume == "yes", nil
+	}
+	if (!Interactive()) {
+		reply := "no"
+		if def {
+			reply = "yes"
+		}
+		Err.Printf("%s %s\n", reply, Err.Dim("(not interactive, pass --yes or --no to choose)"))
+		return def, nil
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	input, err := reader.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF)) {
+		return false, err
+	}
+
+	input = strings.ToLower(strings.TrimSpace(input))
+	if input == "" {
+		return def, nil
+	}
+	return input == "y" || input == "yes", nil
+}
+
+// IsTerminal reports whether f is a terminal rather than a pipe or file
+func IsTerminal(f *os.File) bool {
+	info, _err0 := f.Stat()
+	if _err0 != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// interactive decides whether to prompt. Any CI value other than false or 0
+// counts, since providers set it to true, 1 or their own name.
+func interactive(stdinTTY bool, ci string) bool {
+	if ci != "" && ci != "false" && ci != "0" {
+		return false
+	}
+	return stdinTTY
+}
+
+// colorEnabled decides on colour. An explicit --color wins, then NO_COLOR
+// (https://no-color.org), then CLICOLOR_FORCE, then whether it's a terminal.
+func colorEnabled(mode string, noColor string, force string, tty bool) bool {
+	switch mode {
+	case Always:
+		return true
+	case Never:
+		return false
+	}
+	if noColor != "" {
+		return false
+	}
+	if force != "" && force != "0" {
+		return true
+	}
+	return tty
+}
+
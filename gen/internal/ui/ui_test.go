@@ -0,0 +1,109 @@
+//soppo:generated v1
+package ui
+
+import "testing"
+
+func TestColorEnabled(t *testing.T) {
+	tests := []struct {
+		mode    string
+		noColor string
+		force   string
+		tty     bool
+		want    bool
+	}{
+		{mode: Auto, noColor: "", force: "", tty: true, want: true},
+		{mode: Auto, noColor: "", force: "", tty: false, want: false},
+		{mode: Auto, noColor: "1", force: "", tty: true, want: false},
+		{mode: Auto, noColor: "", force: "1", tty: false, want: true},
+		{mode: Auto, noColor: "", force: "0", tty: false, want: false},
+		{mode: Auto, noColor: "1", force: "1", tty: true, want: false},
+		{mode: Always, noColor: "1", force: "", tty: false, want: true},
+		{mode: Never, noColor: "", force: "1", tty: true, want: false},
+	}
+
+	for _, tt := range tests {
+		got := colorEnabled(tt.mode, tt.noColor, tt.force, tt.tty)
+		if got != tt.want {
+			t.Errorf("colorEnabled(%q, NO_COLOR=%q, CLICOLOR_FORCE=%q, tty=%v) = %v, want %v", tt.mode, tt.noColor, tt.force, tt.tty, got, tt.want)
+		}
+	}
+}
+
+func TestSetColor(t *testing.T) {
+	defer SetColor(Auto)
+
+	for _, mode := range []string{Auto, Always, Never} {
+		_err0 := SetColor(mode)
+		if _err0 != nil {
+			err := _err0
+			t.Errorf("SetColor(%q) returned error: %v", mode, err)
+		}
+	}
+	if err := SetColor("sometimes"); err == nil {
+		t.Error("SetColor(sometimes) should fail")
+	}
+}
+
+func TestStyleRespectsMode(t *testing.T) {
+	defer SetColor(Auto)
+
+	SetColor(Never)
+	if got := Out.Bold("x"); got != "x" {
+		t.Errorf("Bold with --color=never = %q, want plain text", got)
+	}
+	SetColor(Always)
+	if got := Out.Bold("x"); got != "\033[1mx\033[0m" {
+		t.Errorf("Bold with --color=always = %q, want escape codes", got)
+	}
+}
+
+func TestInteractive(t *testing.T) {
+	tests := []struct {
+		tty  bool
+		ci   string
+		want bool
+	}{
+		{tty: true, ci: "", want: true},
+		{tty: false, ci: "", want: false},
+		{tty: true, ci: "true", want: false},
+		{tty: true, ci: "1", want: false},
+		{tty: true, ci: "woodpecker", want: false},
+		{tty: true, ci: "false", want: true},
+		{tty: true, ci: "0", want: true},
+	}
+
+	for _, tt := range tests {
+		got := interactive(tt.tty, tt.ci)
+		if got != tt.want {
+			t.Errorf("interactive(tty=%v, CI=%q) = %v, want %v", tt.tty, tt.ci, got, tt.want)
+		}
+	}
+}
+
+func TestConfirmAssumed(t *testing.T) {
+	defer func() {
+		assume = ""
+	}()
+
+	AssumeYes()
+	answer, _err0 := Confirm("Continue?", false)
+	if _err0 != nil {
+		err := _err0
+		t.Fatalf("Confirm returned error: %v", err)
+	}
+	if (!answer) {
+		t.Error("Confirm with --yes = false, want true")
+	}
+
+	AssumeNo()
+	var _err1 error
+	answer, _err1 = Confirm("Continue?", true)
+	if _err1 != nil {
+		err := _err1
+		t.Fatalf("Confirm returned error: %v", err)
+	}
+	if answer {
+		t.Error("Confirm with --no = true, want false")
+	}
+}
+
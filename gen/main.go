@@ -2,85 +2,101 @@
 package main
 
 import "github.com/halcyonnouveau/soppo/runtime"
-import "bufio"
 import "fmt"
 import "os"
 import "path/filepath"
+import "slices"
+import "sort"
 import "strings"
 import slap "github.com/beanpuppy/slap/gen"
 import "github.com/halcyonnouveau/sopmod/gen/internal/compat"
 import "github.com/halcyonnouveau/sopmod/gen/internal/config"
+import "github.com/halcyonnouveau/sopmod/gen/internal/doctor"
 import "github.com/halcyonnouveau/sopmod/gen/internal/install"
 import "github.com/halcyonnouveau/sopmod/gen/internal/paths"
+import "github.com/halcyonnouveau/sopmod/gen/internal/prune"
+import "github.com/halcyonnouveau/sopmod/gen/internal/report"
 import "github.com/halcyonnouveau/sopmod/gen/internal/shim"
+import "github.com/halcyonnouveau/sopmod/gen/internal/ui"
 
 // Set at build time with -ldflags "-X main.version=v0.2.0"
 var version = "dev"
 
-// Install a Go or sop version
+// Install Go and sop versions
 type InstallCmd struct {
-	Tool string
-	Version string
+	Specs []string
 	Verbose bool
+	Git string
 }
 
 func (cmd InstallCmd) Run() error {
-	switch cmd.Tool {
-	case "go":
-		_, _err0 := install.InstallGo(cmd.Version, cmd.Verbose)
-		if _err0 != nil {
-			return _err0
-		}
-	case "sop":
-		resolved, _err1 := install.InstallSop(cmd.Version, cmd.Verbose)
-		if _err1 != nil {
-			return _err1
-		}
-		// Set as default if no default exists
-		cfg := config.Load()
-		if cfg.DefaultSop == nil {
-			fmt.Printf("\033[36m→\033[0m Setting sop \033[1m%s\033[0m as default (first install)\n", resolved)
-			return setDefaultSop(resolved)
-		}
-	default:
-		return fmt.Errorf("unknown tool '%s'. Use 'go' or 'sop'", cmd.Tool)
+	if len(cmd.Specs) == 0 {
+		return installPinned(cmd.Verbose)
+	}
+	specs, _err0 := install.ParseSpecs(cmd.Specs)
+	if _err0 != nil {
+		return _err0
 	}
 
-	return nil
+	if cmd.Git != "" {
+		if len(specs) != 1 || specs[0].Tool != "sop" {
+			return fmt.Errorf("--git builds a single sop, e.g. `sopmod install sop@dev --git main`")
+		}
+		return install.InstallSopFromGit(specs[0].Version, cmd.Git, cmd.Verbose)
+	}
+	return installSpecs(specs, cmd.Verbose)
 }
 
 // List installed versions
 type ListCmd struct {
 	Tool string
+	Format string
 }
 
 func (cmd ListCmd) Run() error {
 	cfg := config.Load()
 
+	asJSON, _err0 := jsonOutput(cmd.Format)
+	if _err0 != nil {
+		return _err0
+	}
+	if asJSON {
+		goVersions := []string{}
+		sopVersions := []string{}
+		switch cmd.Tool {
+		case "":
+			goVersions = install.ListInstalledGo()
+			sopVersions = install.ListInstalledSop()
+		case "go":
+			goVersions = install.ListInstalledGo()
+		case "sop":
+			sopVersions = install.ListInstalledSop()
+		default:
+			return fmt.Errorf("unknown tool '%s'. Use 'go' or 'sop'", cmd.Tool)
+		}
+		return report.Write(os.Stdout, report.NewVersions(cfg, goVersions, sopVersions, effectiveDefaultSop(cfg), effectiveDefaultGo(cfg)))
+	}
+
 	if cmd.Tool == "" {
 		// List both
 		goVersions := install.ListInstalledGo()
 		sopVersions := install.ListInstalledSop()
 
 		if len(goVersions) == 0 && len(sopVersions) == 0 {
-			fmt.Println("\033[2mNo versions installed\033[0m")
-			fmt.Println("  \033[36mhint:\033[0m run \033[1msopmod install go latest\033[0m to install go")
-			fmt.Println("  \033[36mhint:\033[0m run \033[1msopmod install sop latest\033[0m to install sop")
+			ui.Out.Println(ui.Out.Dim("No versions installed"))
+			ui.Hint("run %s to install go", ui.Err.Bold("sopmod install go latest"))
+			ui.Hint("run %s to install sop", ui.Err.Bold("sopmod install sop latest"))
 		} else {
 			if len(goVersions) > 0 {
-				fmt.Println("\033[1mgo:\033[0m")
+				ui.Out.Println(ui.Out.Bold("go:"))
 				for _, v := range goVersions {
-					fmt.Printf("  %s\n", v)
+					ui.Out.Printf("  %s\n", v)
 				}
 			}
 			if len(sopVersions) > 0 {
-				fmt.Println("\033[1msop:\033[0m")
+				ui.Out.Println(ui.Out.Bold("sop:"))
 				for _, v := range sopVersions {
-					if cfg.DefaultSop != nil && (*cfg.DefaultSop) == v {
-						fmt.Printf("  \033[32m%s\033[0m \033[2m(default)\033[0m\n", v)
-					} else {
-						fmt.Printf("  %s\n", v)
-					}
+					printSopVersion(cfg, v)
 				}
 			}
 		}
@@ -89,25 +105,21 @@ func (cmd ListCmd) Run() error {
 		case "go":
 			versions := install.ListInstalledGo()
 			if len(versions) == 0 {
-				fmt.Println("\033[2mNo go versions installed\033[0m")
+				ui.Out.Println(ui.Out.Dim("No go versions installed"))
 			} else {
-				fmt.Println("\033[1mInstalled go versions:\033[0m")
+				ui.Out.Println(ui.Out.Bold("Installed go versions:"))
 				for _, v := range versions {
-					fmt.Printf("  %s\n", v)
+					ui.Out.Printf("  %s\n", v)
 				}
 			}
 		case "sop":
 			versions := install.ListInstalledSop()
 			if len(versions) == 0 {
-				fmt.Println("\033[2mNo sop versions installed\033[0m")
+				ui.Out.Println(ui.Out.Dim("No sop versions installed"))
 			} else {
-				fmt.Println("\033[1mInstalled sop versions:\033[0m")
+				ui.Out.Println(ui.Out.Bold("Installed sop versions:"))
 				for _, v := range versions {
-					if cfg.DefaultSop != nil && (*cfg.DefaultSop) == v {
-						fmt.Printf("  \033[32m%s\033[0m \033[2m(default)\033[0m\n", v)
-					} else {
-						fmt.Printf("  %s\n", v)
-					}
+					printSopVersion(cfg, v)
 				}
 			}
 		default:
@@ -120,9 +132,17 @@ func (cmd ListCmd) Run() error {
 // Set the default sop version
 type DefaultCmd struct {
 	Version string
+	Toolchain string
 }
 
 func (cmd DefaultCmd) Run() error {
+	if cmd.Toolchain != "" {
+		return setDefaultToolchain(cmd.Toolchain)
+	}
+	if cmd.Version == "" {
+		return fmt.Errorf("specify a version to set as default, or a toolchain with --toolchain")
+	}
+
 	// Resolve version first
 	resolved, _err0 := install.ResolveSopVersion(cmd.Version)
 	if _err0 != nil {
@@ -146,7 +166,11 @@ func (cmd DefaultCmd) Run() error {
 		}
 
 		if shouldInstall {
-			_, _err2 := install.InstallSop(resolved, false)
+			jobs := []install.Job{{Name: "sop " + resolved, Run: func() error {
+				return install.InstallResolvedSop(resolved, false)
+			}}}
+			jobs = append(jobs, compatibleGoJob(resolved, nil)...)
+			_err2 := install.RunJobs(jobs, install.Workers)
 			if _err2 != nil {
 				return _err2
 			}
@@ -155,7 +179,7 @@ func (cmd DefaultCmd) Run() error {
 		}
 	}
 
-	return setDefaultSop(resolved)
+	return setDefaultSop(resolved, sopChannelOf(cmd.Version))
 }
 
 // Remove an installed version
@@ -177,16 +201,32 @@ func (cmd RemoveCmd) Run() error {
 		if _err1 != nil {
 			return _err1
 		}
-		_err2 := install.RemoveSop(resolved)
-		if _err2 != nil {
-			return _err2
-		}
-		// Clear default if it was this version
 		cfg := config.Load()
+		if effectiveDefaultSop(cfg) == resolved {
+			question := fmt.Sprintf("sop %s is the default. Remove it anyway?", ui.Err.Bold(resolved))
+			confirmed, _err2 := ui.Confirm(question, false)
+			if _err2 != nil {
+				return _err2
+			}
+			if (!confirmed) {
+				return nil
+			}
+		}
+		_err3 := install.RemoveSop(resolved)
+		if _err3 != nil {
+			return _err3
+		}
+		// Clear default and channels if they pointed at this version
 		if cfg.DefaultSop != nil && (*cfg.DefaultSop) == resolved {
 			cfg.DefaultSop = nil
-			cfg.Save()
+			cfg.SopChannel = nil
+		}
+		for channel, v := range cfg.Channels {
+			if v == resolved {
+				delete(cfg.Channels, channel)
+			}
 		}
+		cfg.Save()
 		return nil
 	default:
 		return fmt.Errorf("unknown tool '%s'. Use 'go' or 'sop'", cmd.Tool)
@@ -200,11 +240,11 @@ type UpdateCmd struct {
 
 func (cmd UpdateCmd) Run() error {
 	if cmd.Tool == "" {
-		_err0 := updateGo()
-		if _err0 != nil {
-			return _err0
-		}
-		return updateSop()
+		// Both download at once; installing the same go twice waits on the lock
+		return install.RunJobs([]install.Job{
+			{Name: "go", Run: updateGo},
+			{Name: "sop", Run: updateSop},
+		}, install.Workers)
 	}
 
 	switch cmd.Tool {
@@ -217,242 +257,1100 @@ func (cmd UpdateCmd) Run() error {
 	}
 }
 
-// All subcommands
-/*soppo:enum
-Cmd {
-    Install InstallCmd
-    List ListCmd
-    Default DefaultCmd
-    Remove RemoveCmd
-    Update UpdateCmd
-}
-*/
-type Cmd interface {
-	isCmd()
+// Link a local sop build as a named toolchain
+type LinkCmd struct {
+	Tool string
+	Name string
+	Path string
 }
 
-type Cmd_Install struct {
-	Value InstallCmd
+func (cmd LinkCmd) Run() error {
+	if cmd.Tool != "sop" {
+		return fmt.Errorf("unknown tool '%s'. Only sop builds can be linked", cmd.Tool)
+	}
+	return install.LinkSop(cmd.Name, cmd.Path)
 }
-func (Cmd_Install) isCmd() {}
 
-type Cmd_List struct {
-	Value ListCmd
+// Check the sopmod setup for problems
+type DoctorCmd struct {
+	Strict bool
+	Format string
 }
-func (Cmd_List) isCmd() {}
 
-type Cmd_Default struct {
-	Value DefaultCmd
-}
-func (Cmd_Default) isCmd() {}
+func (cmd DoctorCmd) Run() error {
+	asJSON, _err0 := jsonOutput(cmd.Format)
+	if _err0 != nil {
+		return _err0
+	}
+	result := report.NewDoctor(doctor.Run(version), cmd.Strict)
 
-type Cmd_Remove struct {
-	Value RemoveCmd
-}
-func (Cmd_Remove) isCmd() {}
+	if asJSON {
+		_err1 := report.Write(os.Stdout, result)
+		if _err1 != nil {
+			return _err1
+		}
+	} else {
+		for _, check := range result.Checks {
+			switch check.Status {
+			case doctor.Pass:
+				ui.Out.Printf("%s %s\n", ui.Out.Green("✓"), check.Message)
+			case doctor.Warn:
+				ui.Out.Printf("%s %s\n", ui.Out.Yellow("!"), check.Message)
+			case doctor.Fail:
+				ui.Out.Printf("%s %s\n", ui.Out.Red("✗"), check.Message)
+			}
+			if check.Hint != "" {
+				ui.Out.Printf("  %s\n", ui.Out.Dim(check.Hint))
+			}
+		}
+	}
 
-type Cmd_Update struct {
-	Value UpdateCmd
+	if result.Failed > 0 {
+		return fmt.Errorf("%d check(s) failed", result.Failed)
+	}
+	return nil
 }
-func (Cmd_Update) isCmd() {}
 
-func CmdInstall(value InstallCmd) Cmd {
-	return Cmd_Install{Value: value}
-}
-func CmdList(value ListCmd) Cmd {
-	return Cmd_List{Value: value}
-}
-func CmdDefault(value DefaultCmd) Cmd {
-	return Cmd_Default{Value: value}
-}
-func CmdRemove(value RemoveCmd) Cmd {
-	return Cmd_Remove{Value: value}
-}
-func CmdUpdate(value UpdateCmd) Cmd {
-	return Cmd_Update{Value: value}
+// Remove versions nothing uses any more
+type PruneCmd struct {
+	DryRun bool
+	Keep int
 }
 
-func main() {
-	// Check if running as shim (invoked as "sop" or "sopls" not "sopmod")
-	arg0 := os.Args[0]
-	base := filepath.Base(arg0)
-	isSopShim := strings.HasSuffix(base, "sop") && (!strings.HasSuffix(base, "sopmod")) && (!strings.HasSuffix(base, "sopls"))
-	isSoplsShim := strings.HasSuffix(base, "sopls")
+func (cmd PruneCmd) Run() error {
+	cfg := config.Load()
+	installedSop := install.ListInstalledSop()
+	installedGo := install.ListInstalledGo()
 
-	if isSopShim {
-		_err0 := shim.Run()
-		if _err0 != nil {
-			err := _err0
-			fmt.Fprintf(os.Stderr, "\033[31;1merror:\033[0m %s\n", err)
-			os.Exit(1)
-		}
-		return
+	// Registered projects plus the one we're in, whether or not the shim has seen it yet
+	projectFiles := config.LoadProjects()
+	current, _err0 := shim.FindProjectFile()
+	if _err0 != nil {
+		return _err0
+	}
+	if current != "" && (!slices.Contains(projectFiles, current)) {
+		projectFiles = append(projectFiles, current)
 	}
 
-	if isSoplsShim {
-		_err1 := shim.RunLsp()
+	projects := []*config.ProjectConfig{}
+	for _, projectFile := range projectFiles {
+		if _, err := os.Stat(projectFile); err != nil {
+			continue
+		}
+		projectCfg, _err1 := config.LoadPins(projectFile)
 		if _err1 != nil {
 			err := _err1
-			fmt.Fprintf(os.Stderr, "\033[31;1merror:\033[0m %s\n", err)
-			os.Exit(1)
+			return fmt.Errorf("can't read %s, fix it before pruning: %w", projectFile, err)
 		}
-		return
+		projects = append(projects, projectCfg)
 	}
-
-	// Ensure sopmod directories exist
-	_err2 := paths.EnsureDirs()
+	// Versions someone is trying out with `sopmod local` are in use too
+	overrides, _err2 := config.LoadOverrides()
 	if _err2 != nil {
-		err := _err2
-		fmt.Fprintf(os.Stderr, "Failed to create sopmod directories: %s\n", err)
-		os.Exit(1)
+		return _err2
 	}
-
-	_err3 := slap.Run[Cmd]()
-	if _err3 != nil {
-		err := _err3
-		fmt.Fprintf(os.Stderr, "\033[31;1merror:\033[0m %s\n", err)
-		os.Exit(1)
+	for _, pins := range overrides {
+		projects = append(projects, (&pins))
 	}
-}
 
-func setDefaultSop(version string) error {
-	cfg := config.Load()
-	cfg.DefaultSop = (&version)
+	usedSop, usedGo := prune.UsedVersions(cfg, projects, installedSop, installedGo)
 
-	// Install shim
-	err := shim.Install()
-	if err != nil {
-		return err
+	targets := []pruneTarget{}
+	for _, v := range prune.Candidates(installedSop, usedSop, cmd.Keep) {
+		// Linked and git-built toolchains are managed by hand
+		if install.SopSource(v) == "" {
+			targets = append(targets, pruneTarget{tool: "sop", version: v, dir: paths.SopDir(v), remove: install.RemoveSop})
+		}
+	}
+	for _, v := range prune.Candidates(installedGo, usedGo, cmd.Keep) {
+		targets = append(targets, pruneTarget{tool: "go", version: v, dir: paths.GoDir(v), remove: install.RemoveGo})
+	}
+	if len(targets) == 0 {
+		ui.Out.Println(ui.Out.Dim("Nothing to prune"))
+		return nil
 	}
 
-	fmt.Printf("\033[32m✓\033[0m Default sop version set to \033[1m%s\033[0m\n", version)
+	var freed int64
+	for _, target := range targets {
+		size, _err3 := install.DirSize(target.dir)
+		if _err3 != nil {
+			return _err3
+		}
+		ui.Out.Printf("Would remove %s %s %s\n", target.tool, ui.Out.Bold(target.version), ui.Out.Dim("(" + prune.FormatSize(size) + ")"))
+		freed += size
+	}
+	if cmd.DryRun {
+		ui.Out.Printf("Would free %s\n", ui.Out.Bold(prune.FormatSize(freed)))
+		return nil
+	}
 
-	// Auto-set compatible Go version
-	goVersion, _err0 := findOrInstallCompatibleGo(version)
-	if _err0 != nil {
-		return _err0
+	question := fmt.Sprintf("Remove %d versions, freeing %s?", len(targets), ui.Err.Bold(prune.FormatSize(freed)))
+	confirmed, _err4 := ui.Confirm(question, false)
+	if _err4 != nil {
+		return _err4
+	}
+	if (!confirmed) {
+		return nil
 	}
+	for _, target := range targets {
+		_err5 := target.remove(target.version)
+		if _err5 != nil {
+			return _err5
+		}
+	}
+	ui.Success("Freed %s", ui.Err.Bold(prune.FormatSize(freed)))
+	return nil
+}
 
-	if goVersion != "" {
-		cfg.DefaultGo = (&goVersion)
-		fmt.Printf("\033[32m✓\033[0m Default go version set to \033[1m%s\033[0m (compatible with sop %s)\n", goVersion, version)
+// List the projects the shim has run in
+type ProjectsCmd struct {
+}
+
+func (cmd ProjectsCmd) Run() error {
+	cfg := config.Load()
+	registered := config.LoadProjects()
+	if len(registered) == 0 {
+		ui.Out.Println(ui.Out.Dim("No projects recorded yet. Projects are added the first time sop runs in them."))
+		return nil
 	}
 
-	_err1 := cfg.Save()
-	if _err1 != nil {
-		return _err1
+	installedSop := install.ListInstalledSop()
+	installedGo := install.ListInstalledGo()
+
+	// Forget projects whose version file has gone away
+	kept := []string{}
+	for _, projectFile := range registered {
+		if _, err := os.Stat(projectFile); err != nil {
+			continue
+		}
+		kept = append(kept, projectFile)
+
+		ui.Out.Println(ui.Out.Bold(filepath.Dir(projectFile)))
+		projectCfg, _err0 := config.LoadPins(projectFile)
+		if _err0 != nil {
+			err := _err0
+			ui.Out.Printf("  %s %s\n", ui.Out.Red("✗"), err)
+			continue
+		}
+		printProjectPins(cfg, projectCfg, installedSop, installedGo)
 	}
 
-	printPathHint()
+	if len(kept) != len(registered) {
+		return config.SaveProjects(kept)
+	}
 	return nil
 }
 
-func promptInstall(tool string, version string) (bool, error) {
-	fmt.Printf("\033[1m%s\033[0m \033[1m%s\033[0m is not installed. Install it? [Y/n] ", tool, version)
+// Update or uninstall sopmod itself
+type SelfCmd struct {
+	Action string
+	Verbose bool
+}
 
-	reader := bufio.NewReader(os.Stdin)
-	input, err := reader.ReadString('\n')
-	if err != nil {
-		return false, err
+func (cmd SelfCmd) Run() error {
+	switch cmd.Action {
+	case "update":
+		return selfUpdate(cmd.Verbose)
+	case "uninstall":
+		return selfUninstall()
+	default:
+		return fmt.Errorf("unknown action '%s'. Use 'update' or 'uninstall'", cmd.Action)
 	}
+}
 
-	input = strings.ToLower(strings.TrimSpace(input))
-	return input == "" || input == "y" || input == "yes", nil
+// Manage the sop and sopls shims
+type ShimCmd struct {
+	Action string
 }
 
-func printPathHint() {
-	binDir := paths.BinDir()
-	pathVar := os.Getenv("PATH")
-	if strings.Contains(pathVar, binDir) {
-		return
+func (cmd ShimCmd) Run() error {
+	switch cmd.Action {
+	case "refresh":
+		_err0 := shim.Install(version)
+		if _err0 != nil {
+			return _err0
+		}
+		ui.Success("Shims refreshed in %s", ui.Err.Bold(paths.BinDir()))
+		return nil
+	default:
+		return fmt.Errorf("unknown action '%s'. Use 'refresh'", cmd.Action)
 	}
+}
 
-	fmt.Println()
-	fmt.Printf("\033[36mhint:\033[0m Add \033[1m~/.sopmod/bin\033[0m to your PATH:\n")
-	fmt.Println()
-	fmt.Println("  \033[2mexport PATH=\"$HOME/.sopmod/bin:$PATH\"\033[0m")
+// Show which binary a tool resolves to here
+type WhichCmd struct {
+	Tool string
+	Format string
 }
 
-func updateGo() error {
-	latest, _err0 := install.ResolveGoVersion("latest")
+func (cmd WhichCmd) Run() error {
+	asJSON, _err0 := jsonOutput(cmd.Format)
 	if _err0 != nil {
 		return _err0
 	}
-
-	installed := install.ListInstalledGo()
-	for _, v := range installed {
-		if v == latest {
-			fmt.Printf("\033[32m✓\033[0m go \033[1m%s\033[0m is already the latest version\n", latest)
-			return nil
-		}
+	tool := cmd.Tool
+	if tool == "" {
+		tool = "sop"
 	}
 
-	_, err := install.InstallGo(latest, false)
-	return err
+	res, _err1 := shim.Resolve()
+	if _err1 != nil {
+		return _err1
+	}
+	w, _err2 := report.NewWhich(tool, res)
+	if _err2 != nil {
+		return _err2
+	}
+	if asJSON {
+		return report.Write(os.Stdout, w)
+	}
+	ui.Out.Println(w.Path)
+	return nil
 }
 
-func updateSop() error {
-	cfg := config.Load()
-	oldDefault := cfg.DefaultSop
+// Show the environment the shims run tools with
+type EnvCmd struct {
+	Format string
+}
 
-	latest, _err0 := install.ResolveSopVersion("latest")
+func (cmd EnvCmd) Run() error {
+	asJSON, _err0 := jsonOutput(cmd.Format)
 	if _err0 != nil {
 		return _err0
 	}
-
-	installed := install.ListInstalledSop()
-	alreadyInstalled := false
-	for _, v := range installed {
-		if v == latest {
-			alreadyInstalled = true
-			break
-		}
+	res, _err1 := shim.Resolve()
+	if _err1 != nil {
+		return _err1
 	}
-
-	if alreadyInstalled {
-		fmt.Printf("\033[32m✓\033[0m sop \033[1m%s\033[0m is already the latest version\n", latest)
-	} else {
-		_, _err1 := install.InstallSop(latest, false)
-		if _err1 != nil {
-			return _err1
-		}
+	env := report.NewEnv(res)
+	if asJSON {
+		return report.Write(os.Stdout, env)
 	}
 
-	// Update default if needed
-	shouldUpdateDefault := oldDefault == nil
-	if oldDefault != nil {
-		for _, v := range installed {
-			if v == (*oldDefault) {
-				shouldUpdateDefault = true
-				break
-			}
-		}
+	// Text output can be eval'd by a shell
+	ui.Out.Printf("# sop %s (%s)\n", env.Sop.Version, env.Sop.Wanted)
+	if env.Go != nil {
+		ui.Out.Printf("# go %s (%s)\n", env.Go.Version, env.Go.Wanted)
+	}
+	if env.Project != nil {
+		ui.Out.Printf("# project %s\n", (*env.Project))
 	}
+	if res.Local != "" {
+		ui.Out.Printf("# local override set on %s\n", res.Local)
+	}
+	for _, dir := range env.Path {
+		ui.Out.Printf("export PATH=\"%s%c$PATH\"\n", dir, os.PathListSeparator)
+	}
+	return nil
+}
 
-	if shouldUpdateDefault && (cfg.DefaultSop == nil || (*cfg.DefaultSop) != latest) {
-		cfg.DefaultSop = (&latest)
+// Manage the Go compatibility manifest
+type CompatCmd struct {
+	Action string
+	Verbose bool
+}
 
-		_err2 := shim.Install()
-		if _err2 != nil {
-			return _err2
+func (cmd CompatCmd) Run() error {
+	switch cmd.Action {
+	case "refresh":
+		tag, _err0 := install.RefreshCompat(cmd.Verbose)
+		if _err0 != nil {
+			return _err0
 		}
+		ui.Success("Go compatibility manifest updated from soppo %s", ui.Err.Bold(tag))
+		if _, err := os.Stat(paths.CompatFile()); err == nil {
+			ui.Warn("%s overrides it until you delete it", paths.CompatFile())
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown action '%s'. Use 'refresh'", cmd.Action)
+	}
+}
+
+// Pin a version in the project's version file
+type PinCmd struct {
+	Tool string
+	Version string
+	File string
+}
+
+func (cmd PinCmd) Run() error {
+	version := cmd.Version
+	var installed []string
+	resolveInstalled := shim.ResolveInstalledVersion
+	switch cmd.Tool {
+	case "go":
+		if version == "latest" {
+			var _err0 error
+			version, _err0 = install.ResolveLatestGo()
+			if _err0 != nil {
+				return _err0
+			}
+		}
+		_, _err1 := compat.ParseGoVersion(version)
+		if _err1 != nil {
+			return fmt.Errorf("invalid go version '%s'", cmd.Version)
+		}
+		installed = install.ListInstalledGo()
+		resolveInstalled = shim.ResolveInstalledGo
+	case "sop":
+		if version == "latest" {
+			var _err2 error
+			version, _err2 = install.ResolveLatestSop()
+			if _err2 != nil {
+				return _err2
+			}
+		}
+		installed = install.ListInstalledSop()
+	default:
+		return fmt.Errorf("unknown tool '%s'. Use 'go' or 'sop'", cmd.Tool)
+	}
+
+	path, _err3 := projectFileFor(cmd.File)
+	if _err3 != nil {
+		return _err3
+	}
+	_err4 := config.SetPin(path, cmd.Tool, version)
+	if _err4 != nil {
+		return _err4
+	}
+	ui.Success("Pinned %s %s in %s", cmd.Tool, ui.Err.Bold(version), path)
+	nearest, _err5 := shim.FindProjectFile()
+	if _err5 != nil {
+		return _err5
+	}
+	if nearest != path {
+		ui.Warn("%s takes precedence over %s", nearest, path)
+	}
+
+	if (!install.IsSopChannel(version)) && resolveInstalled(version, installed) == "" {
+		ui.Hint("run %s to install it", ui.Err.Bold("sopmod install " + cmd.Tool + " " + version))
+	}
+	return nil
+}
+
+// Move the project's pins to the newest releases
+type BumpCmd struct {
+	Tool string
+}
+
+func (cmd BumpCmd) Run() error {
+	switch cmd.Tool {
+	case "", "go", "sop":
+	default:
+		return fmt.Errorf("unknown tool '%s'. Use 'go' or 'sop'", cmd.Tool)
+	}
+
+	path, _err0 := shim.FindProjectFile()
+	if _err0 != nil {
+		return _err0
+	}
+	if path == "" {
+		return fmt.Errorf("no sop.mod, .sop-version or .tool-versions found. Use 'sopmod pin' to create one")
+	}
+	project, _err1 := config.LoadVersionFile(path)
+	if _err1 != nil {
+		err := _err1
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for _, warning := range project.Warnings {
+		ui.Warn("%s: %s", path, warning)
+	}
+	projectCfg := project.Config
+
+	// The sop the go pin has to work with, after any sop bump
+	sopVersion := effectiveDefaultSop(config.Load())
+	changed := false
+
+	if projectCfg.Sop != nil {
+		pin := (*projectCfg.Sop)
+		sopVersion = pin
+		if install.IsSopChannel(pin) {
+			var _err2 error
+			sopVersion, _err2 = install.ResolveSopChannel(pin)
+			if _err2 != nil {
+				return _err2
+			}
+			if cmd.Tool != "go" {
+				ui.Progress("sop follows the %s channel, leaving it", pin)
+			}
+		} else {
+			if cmd.Tool != "go" {
+				latest, _err3 := install.ResolveLatestSop()
+				if _err3 != nil {
+					return _err3
+				}
+				bumped, order, _err4 := config.BumpPin(path, "sop", pin, latest)
+				if _err4 != nil {
+					return _err4
+				}
+				if order > 0 {
+					ui.Success("sop %s → %s", pin, ui.Err.Bold(bumped))
+					sopVersion = bumped
+					changed = true
+				}
+			}
+		}
+	}
+
+	if projectCfg.Go != nil && cmd.Tool != "sop" {
+		pin := (*projectCfg.Go)
+		latest, _err5 := install.ResolveLatestCompatibleGo(sopVersion)
+		if _err5 != nil {
+			return _err5
+		}
+		bumped, order, _err6 := config.BumpPin(path, "go", pin, latest)
+		if _err6 != nil {
+			return _err6
+		}
+		if order > 0 {
+			ui.Success("go %s → %s", pin, ui.Err.Bold(bumped))
+			changed = true
+		} else {
+			if order < 0 {
+				ui.Warn("go %s is newer than sop %s supports, the newest that works is %s", pin, sopVersion, latest)
+			}
+		}
+	}
+
+	if (!changed) {
+		ui.Out.Println(ui.Out.Dim("Pins are already up to date"))
+	}
+	return nil
+}
+
+// Override versions for a directory without touching the project's files
+type LocalCmd struct {
+	Version string
+	Go bool
+	Unset bool
+}
+
+func (cmd LocalCmd) Run() error {
+	dir, _err0 := os.Getwd()
+	if _err0 != nil {
+		return _err0
+	}
+	overrides, _err1 := config.LoadOverrides()
+	if _err1 != nil {
+		return _err1
+	}
+	tool := "sop"
+	if cmd.Go {
+		tool = "go"
+	}
+
+	if cmd.Unset {
+		if cmd.Version != "" {
+			return fmt.Errorf("--unset doesn't take a version")
+		}
+		pins, ok := overrides[dir]
+		if (!ok) {
+			ui.Out.Println(ui.Out.Dim("No override set in this directory"))
+			return nil
+		}
+		if cmd.Go && pins.Sop != nil {
+			pins.Go = nil
+			overrides[dir] = pins
+		} else {
+			delete(overrides, dir)
+		}
+		_err2 := overrides.Save()
+		if _err2 != nil {
+			return _err2
+		}
+		ui.Success("Removed the override for %s", dir)
+		return nil
+	}
+
+	if cmd.Version == "" {
+		covering, pins := overrides.For(dir)
+		if pins == nil {
+			ui.Out.Println(ui.Out.Dim("No override set in this directory"))
+			return nil
+		}
+		if pins.Sop != nil {
+			ui.Out.Printf("sop %s\n", (*pins.Sop))
+		}
+		if pins.Go != nil {
+			ui.Out.Printf("go %s\n", (*pins.Go))
+		}
+		if covering != dir {
+			ui.Out.Println(ui.Out.Dim("(set on " + covering + ")"))
+		}
+		return nil
+	}
+
+	version := cmd.Version
+	_err3 := config.ValidatePin(tool, version)
+	if _err3 != nil {
+		return _err3
+	}
+	pins := overrides[dir]
+	if cmd.Go {
+		pins.Go = (&version)
+	} else {
+		pins.Sop = (&version)
+	}
+	overrides[dir] = pins
+	_err4 := overrides.Save()
+	if _err4 != nil {
+		return _err4
+	}
+	ui.Success("Using %s %s in %s, just for you", tool, ui.Err.Bold(version), dir)
+	ui.Hint("run %s to go back to the project's pins", ui.Err.Bold("sopmod local --unset"))
+	return nil
+}
+
+// All subcommands
+/*soppo:enum
+Cmd {
+    Install InstallCmd
+    List ListCmd
+    Default DefaultCmd
+    Remove RemoveCmd
+    Update UpdateCmd
+    Link LinkCmd
+    Doctor DoctorCmd
+    Prune PruneCmd
+    Projects ProjectsCmd
+    Self SelfCmd
+    Shim ShimCmd
+    Which WhichCmd
+    Env EnvCmd
+    Compat CompatCmd
+    Pin PinCmd
+    Bump BumpCmd
+    Local LocalCmd
+}
+*/
+type Cmd interface {
+	isCmd()
+}
+
+type Cmd_Install struct {
+	Value InstallCmd
+}
+func (Cmd_Install) isCmd() {}
+
+type Cmd_List struct {
+	Value ListCmd
+}
+func (Cmd_List) isCmd() {}
+
+type Cmd_Default struct {
+	Value DefaultCmd
+}
+func (Cmd_Default) isCmd() {}
+
+type Cmd_Remove struct {
+	Value RemoveCmd
+}
+func (Cmd_Remove) isCmd() {}
+
+type Cmd_Update struct {
+	Value UpdateCmd
+}
+func (Cmd_Update) isCmd() {}
+
+type Cmd_Link struct {
+	Value LinkCmd
+}
+func (Cmd_Link) isCmd() {}
+
+type Cmd_Doctor struct {
+	Value DoctorCmd
+}
+func (Cmd_Doctor) isCmd() {}
+
+type Cmd_Prune struct {
+	Value PruneCmd
+}
+func (Cmd_Prune) isCmd() {}
+
+type Cmd_Projects struct {
+	Value ProjectsCmd
+}
+func (Cmd_Projects) isCmd() {}
+
+type Cmd_Self struct {
+	Value SelfCmd
+}
+func (Cmd_Self) isCmd() {}
+
+type Cmd_Shim struct {
+	Value ShimCmd
+}
+func (Cmd_Shim) isCmd() {}
+
+type Cmd_Which struct {
+	Value WhichCmd
+}
+func (Cmd_Which) isCmd() {}
+
+type Cmd_Env struct {
+	Value EnvCmd
+}
+func (Cmd_Env) isCmd() {}
+
+type Cmd_Compat struct {
+	Value CompatCmd
+}
+func (Cmd_Compat) isCmd() {}
+
+type Cmd_Pin struct {
+	Value PinCmd
+}
+func (Cmd_Pin) isCmd() {}
+
+type Cmd_Bump struct {
+	Value BumpCmd
+}
+func (Cmd_Bump) isCmd() {}
+
+type Cmd_Local struct {
+	Value LocalCmd
+}
+func (Cmd_Local) isCmd() {}
+
+func CmdInstall(value InstallCmd) Cmd {
+	return Cmd_Install{Value: value}
+}
+func CmdList(value ListCmd) Cmd {
+	return Cmd_List{Value: value}
+}
+func CmdDefault(value DefaultCmd) Cmd {
+	return Cmd_Default{Value: value}
+}
+func CmdRemove(value RemoveCmd) Cmd {
+	return Cmd_Remove{Value: value}
+}
+func CmdUpdate(value UpdateCmd) Cmd {
+	return Cmd_Update{Value: value}
+}
+func CmdLink(value LinkCmd) Cmd {
+	return Cmd_Link{Value: value}
+}
+func CmdDoctor(value DoctorCmd) Cmd {
+	return Cmd_Doctor{Value: value}
+}
+func CmdPrune(value PruneCmd) Cmd {
+	return Cmd_Prune{Value: value}
+}
+func CmdProjects(value ProjectsCmd) Cmd {
+	return Cmd_Projects{Value: value}
+}
+func CmdSelf(value SelfCmd) Cmd {
+	return Cmd_Self{Value: value}
+}
+func CmdShim(value ShimCmd) Cmd {
+	return Cmd_Shim{Value: value}
+}
+func CmdWhich(value WhichCmd) Cmd {
+	return Cmd_Which{Value: value}
+}
+func CmdEnv(value EnvCmd) Cmd {
+	return Cmd_Env{Value: value}
+}
+func CmdCompat(value CompatCmd) Cmd {
+	return Cmd_Compat{Value: value}
+}
+func CmdPin(value PinCmd) Cmd {
+	return Cmd_Pin{Value: value}
+}
+func CmdBump(value BumpCmd) Cmd {
+	return Cmd_Bump{Value: value}
+}
+func CmdLocal(value LocalCmd) Cmd {
+	return Cmd_Local{Value: value}
+}
+
+func main() {
+	// Check if running as shim (invoked as "sop" or "sopls" not "sopmod")
+	shimName := shim.Name(os.Args[0])
+
+	if shimName == "sop" {
+		_err0 := shim.Run()
+		if _err0 != nil {
+			err := _err0
+			ui.Error(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if shimName == "sopls" {
+		_err1 := shim.RunLsp()
+		if _err1 != nil {
+			err := _err1
+			ui.Error(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	args, _err2 := applyGlobalFlags(os.Args[1:])
+	if _err2 != nil {
+		err := _err2
+		ui.Error(err)
+		os.Exit(1)
+	}
+	os.Args = append(os.Args[:1], args...)
+
+	// Ensure sopmod directories exist
+	_err3 := paths.EnsureDirs()
+	if _err3 != nil {
+		err := _err3
+		ui.Error(fmt.Errorf("failed to create sopmod directories: %w", err))
+		os.Exit(1)
+	}
+
+	// Hardlinked or copied shims keep running the old sopmod until refreshed
+	refreshing := len(os.Args) > 2 && os.Args[1] == "shim"
+	if (!refreshing) && shim.IsStale(paths.SopShim(), version) {
+		ui.Warn("the sop shims come from a different sopmod. Run `sopmod shim refresh`")
+	}
+
+	_err4 := slap.Run[Cmd]()
+	if _err4 != nil {
+		err := _err4
+		ui.Error(err)
+		os.Exit(1)
+	}
+}
+
+// applyGlobalFlags handles the flags every command takes and returns the
+// remaining arguments. slap only knows about flags on subcommands.
+func applyGlobalFlags(args []string) ([]string, error) {
+	rest := []string{}
+	yes, no := false, false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return append(rest, args[i:]...), nil
+		}
+		if arg == "--yes" || arg == "-y" {
+			ui.AssumeYes()
+			yes = true
+		} else {
+			if arg == "--no" {
+				ui.AssumeNo()
+				no = true
+			} else {
+				if arg == "--color" && i+1 < len(args) {
+					_err0 := ui.SetColor(args[i+1])
+					if _err0 != nil {
+						return nil, _err0
+					}
+					i++
+				} else {
+					if strings.HasPrefix(arg, "--color=") {
+						_err1 := ui.SetColor(strings.TrimPrefix(arg, "--color="))
+						if _err1 != nil {
+							return nil, _err1
+						}
+					} else {
+						rest = append(rest, arg)
+					}
+				}
+			}
+		}
+	}
+	if yes && no {
+		return nil, fmt.Errorf("--yes and --no can't be used together")
+	}
+	return rest, nil
+}
+
+func setDefaultSop(sopVersion string, channel string) error {
+	cfg := config.Load()
+	cfg.DefaultSop = (&sopVersion)
+	cfg.DefaultToolchain = nil
+	cfg.SopChannel = nil
+	if channel != "" {
+		cfg.SopChannel = (&channel)
+		cfg.SetChannel(channel, sopVersion)
+	}
+
+	// Install shim
+	err := shim.Install(version)
+	if err != nil {
+		return err
+	}
+
+	if channel != "" {
+		ui.Success("Default sop version set to %s (following %s)", ui.Err.Bold(sopVersion), channel)
+	} else {
+		ui.Success("Default sop version set to %s", ui.Err.Bold(sopVersion))
+	}
+
+	// Auto-set compatible Go version
+	goVersion, _err0 := findOrInstallCompatibleGo(sopVersion)
+	if _err0 != nil {
+		return _err0
+	}
+
+	if goVersion != "" {
+		cfg.DefaultGo = (&goVersion)
+		ui.Success("Default go version set to %s (compatible with sop %s)", ui.Err.Bold(goVersion), sopVersion)
+	}
+
+	_err1 := cfg.Save()
+	if _err1 != nil {
+		return _err1
+	}
+
+	printPathHint()
+	return nil
+}
+
+func setDefaultToolchain(name string) error {
+	cfg := config.Load()
+	tc, _err0 := cfg.FindToolchain(name)
+	if _err0 != nil {
+		return _err0
+	}
+
+	// Both halves of the pairing need to be installed. Ask about both, then
+	// fetch them together.
+	jobs := []install.Job{}
+	sopVersion := shim.ResolveInstalledVersion(tc.Sop, install.ListInstalledSop())
+	if sopVersion == "" {
+		shouldInstall, _err1 := promptInstall("sop", tc.Sop)
+		if _err1 != nil {
+			return _err1
+		}
+		if (!shouldInstall) {
+			return nil
+		}
+		jobs = append(jobs, install.Job{Name: "sop " + tc.Sop, Run: func() error {
+			var _err2 error
+			sopVersion, _err2 = install.InstallSop(tc.Sop, false)
+			if _err2 != nil {
+				return _err2
+			}
+			return nil
+		}})
+	}
+
+	goVersion := shim.ResolveInstalledGo(tc.Go, install.ListInstalledGo())
+	if goVersion == "" {
+		shouldInstall, _err3 := promptInstall("go", tc.Go)
+		if _err3 != nil {
+			return _err3
+		}
+		if (!shouldInstall) {
+			return nil
+		}
+		jobs = append(jobs, install.Job{Name: "go " + tc.Go, Run: func() error {
+			var _err4 error
+			goVersion, _err4 = install.InstallGo(tc.Go, false)
+			if _err4 != nil {
+				return _err4
+			}
+			return nil
+		}})
+	}
+	_err5 := install.RunJobs(jobs, install.Workers)
+	if _err5 != nil {
+		return _err5
+	}
+
+	if (!compat.IsGoCompatible(goVersion, sopVersion)) {
+		ui.Warn("%s, but toolchain %s pairs it with go %s", compat.CompatMessage(sopVersion), name, goVersion)
+	}
+
+	cfg.DefaultToolchain = (&name)
+
+	_err6 := shim.Install(version)
+	if _err6 != nil {
+		return _err6
+	}
+	_err7 := cfg.Save()
+	if _err7 != nil {
+		return _err7
+	}
+
+	ui.Success("Default toolchain set to %s (sop %s, go %s)", ui.Err.Bold(name), sopVersion, goVersion)
+	printPathHint()
+	return nil
+}
 
-		fmt.Printf("\033[32m✓\033[0m Default sop version updated to \033[1m%s\033[0m\n", latest)
+func promptInstall(tool string, version string) (bool, error) {
+	return ui.Confirm(fmt.Sprintf("%s %s is not installed. Install it?", ui.Err.Bold(tool), ui.Err.Bold(version)), true)
+}
 
-		goVersion, _err3 := findOrInstallCompatibleGo(latest)
-		if _err3 != nil {
-			return _err3
+func selfUpdate(verbose bool) error {
+	exe, newVersion, _err0 := install.UpdateSopmod(version, verbose)
+	if _err0 != nil {
+		return _err0
+	}
+	if exe == "" {
+		return nil
+	}
+
+	// Symlinked shims follow the new binary already, hardlinks and copies don't
+	if info, err := os.Lstat(paths.SopShim()); err == nil && info.Mode()&os.ModeSymlink == 0 {
+		_err1 := shim.InstallFrom(exe, newVersion)
+		if _err1 != nil {
+			return _err1
+		}
+		ui.Success("Shims refreshed")
+	}
+	return nil
+}
+
+func selfUninstall() error {
+	root := paths.SopmodDir()
+	ui.Err.Printf("This removes %s, including every installed sop and go version.\n", ui.Err.Bold(root))
+	confirmed, _err0 := ui.Confirm("Uninstall sopmod?", false)
+	if _err0 != nil {
+		return _err0
+	}
+	if (!confirmed) {
+		return nil
+	}
+
+	_err1 := os.RemoveAll(root)
+	if _err1 != nil {
+		return _err1
+	}
+	ui.Success("Removed %s", root)
+
+	// sopmod itself may live outside the root if it was installed by hand
+	if exe, err := os.Executable(); err == nil && (!strings.HasPrefix(exe, root)) {
+		ui.Err.Printf("  Delete %s to finish uninstalling\n", ui.Err.Bold(exe))
+	}
+	ui.Err.Printf("  Remove %s from your PATH\n", ui.Err.Bold("~/.sopmod/bin"))
+	return nil
+}
+
+func printPathHint() {
+	binDir := paths.BinDir()
+	pathVar := os.Getenv("PATH")
+	if strings.Contains(pathVar, binDir) {
+		return
+	}
+
+	ui.Err.Println()
+	ui.Hint("Add %s to your PATH:", ui.Err.Bold("~/.sopmod/bin"))
+	ui.Err.Println()
+	ui.Err.Println("  " + ui.Err.Dim("export PATH=\"$HOME/.sopmod/bin:$PATH\""))
+}
+
+func updateGo() error {
+	latest, _err0 := install.ResolveGoVersion("latest")
+	if _err0 != nil {
+		return _err0
+	}
+
+	installed := install.ListInstalledGo()
+	for _, v := range installed {
+		if v == latest {
+			ui.Success("go %s is already the latest version", ui.Err.Bold(latest))
+			return nil
+		}
+	}
+
+	return install.InstallResolvedGo(latest, false)
+}
+
+func updateSop() error {
+	cfg := config.Load()
+	oldDefault := cfg.DefaultSop
+	installed := install.ListInstalledSop()
+
+	// The default follows its channel, or stable if it was set to a fixed version
+	channel := "stable"
+	if cfg.SopChannel != nil {
+		channel = (*cfg.SopChannel)
+	}
+
+	latest, _err0 := updateSopChannel((&cfg), channel, installed)
+	if _err0 != nil {
+		return _err0
+	}
+
+	// Update default if needed. Linked and git-built toolchains stay put.
+	shouldUpdateDefault := oldDefault == nil
+	if oldDefault != nil && install.SopSource((*oldDefault)) == "" {
+		for _, v := range installed {
+			if v == (*oldDefault) {
+				shouldUpdateDefault = true
+				break
+			}
+		}
+	}
+
+	if shouldUpdateDefault && (cfg.DefaultSop == nil || (*cfg.DefaultSop) != latest) {
+		cfg.DefaultSop = (&latest)
+
+		_err1 := shim.Install(version)
+		if _err1 != nil {
+			return _err1
+		}
+
+		ui.Success("Default sop version updated to %s", ui.Err.Bold(latest))
+
+		goVersion, _err2 := findOrInstallCompatibleGo(latest)
+		if _err2 != nil {
+			return _err2
 		}
 
 		if goVersion != "" {
 			cfg.DefaultGo = (&goVersion)
-			fmt.Printf("\033[32m✓\033[0m Default go version set to \033[1m%s\033[0m (compatible with sop %s)\n", goVersion, latest)
+			ui.Success("Default go version set to %s (compatible with sop %s)", ui.Err.Bold(goVersion), latest)
 		}
+	}
 
-		cfg.Save()
+	// Move any other channels that projects pin forward too
+	others := []string{}
+	for name := range cfg.Channels {
+		if name != channel {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	for _, name := range others {
+		_, _err3 := updateSopChannel((&cfg), name, installed)
+		if _err3 != nil {
+			return _err3
+		}
 	}
 
-	return nil
+	return cfg.Save()
+}
+
+// updateSopChannel installs the newest release on a channel and records it in cfg
+func updateSopChannel(cfg *config.Config, channel string, installed []string) (string, error) {
+	latest, _err0 := install.ResolveSopChannel(channel)
+	if _err0 != nil {
+		return "", _err0
+	}
+
+	alreadyInstalled := false
+	for _, v := range installed {
+		if v == latest {
+			alreadyInstalled = true
+			break
+		}
+	}
+
+	if alreadyInstalled {
+		if channel == "stable" {
+			ui.Success("sop %s is already the latest version", ui.Err.Bold(latest))
+		} else {
+			ui.Success("sop %s is already the latest %s version", ui.Err.Bold(latest), channel)
+		}
+	} else {
+		_, _err1 := install.InstallSop(latest, false)
+		if _err1 != nil {
+			return "", _err1
+		}
+	}
+
+	cfg.SetChannel(channel, latest)
+	return latest, nil
 }
 
 func findOrInstallCompatibleGo(sopVersion string) (string, error) {
@@ -461,41 +1359,414 @@ func findOrInstallCompatibleGo(sopVersion string) (string, error) {
 		return "", nil
 	}
 
+	if best := shim.BestCompatibleGo(sopVersion, install.ListInstalledGo()); best != "" {
+		return best, nil
+	}
+
+	// No compatible Go installed, install latest
+	ui.Step("Installing go (sop %s requires %s)...", sopVersion, ui.Err.Bold(compatInfo.Min + "+"))
+	return install.InstallGo("latest", false)
+}
+
+// compatibleGoJob installs the newest go next to sopVersion when sop needs a
+// go and nothing installed, or being installed alongside, suits it. Making
+// sop the default then finds that go in place rather than downloading it after.
+func compatibleGoJob(sopVersion string, installingGo []string) []install.Job {
+	compatInfo := compat.GoCompatFor(sopVersion)
+	if compatInfo == nil || shim.BestCompatibleGo(sopVersion, append(install.ListInstalledGo(), installingGo...)) != "" {
+		return nil
+	}
+	minGo := compatInfo.Min
+	return []install.Job{{Name: "go latest", Run: func() error {
+		ui.Step("Installing go (sop %s requires %s)...", sopVersion, ui.Err.Bold(minGo + "+"))
+		_, err := install.InstallGo("latest", false)
+		return err
+	}}}
+}
+
+// sopChannelOf returns the channel a requested sop version follows, or "" for a fixed version
+func sopChannelOf(version string) string {
+	if version == "latest" {
+		return "stable"
+	}
+	if install.IsSopChannel(version) {
+		return version
+	}
+	return ""
+}
+
+// effectiveDefaultSop returns the installed sop the shim uses outside projects
+func effectiveDefaultSop(cfg config.Config) string {
+	if cfg.DefaultToolchain != nil {
+		tc, _err0 := cfg.FindToolchain((*cfg.DefaultToolchain))
+		if _err0 != nil {
+			return ""
+		}
+		return shim.ResolveInstalledVersion(tc.Sop, install.ListInstalledSop())
+	}
+	if cfg.DefaultSop != nil {
+		return (*cfg.DefaultSop)
+	}
+	return ""
+}
+
+// effectiveDefaultGo returns the installed go the shim uses outside projects
+func effectiveDefaultGo(cfg config.Config) string {
+	installed := install.ListInstalledGo()
+	if cfg.DefaultToolchain != nil {
+		tc, _err0 := cfg.FindToolchain((*cfg.DefaultToolchain))
+		if _err0 != nil {
+			return ""
+		}
+		return shim.ResolveInstalledGo(tc.Go, installed)
+	}
+	if cfg.DefaultGo != nil {
+		return shim.ResolveInstalledGo((*cfg.DefaultGo), installed)
+	}
+	return ""
+}
+
+// jsonOutput reports whether --format asks for JSON
+func jsonOutput(format string) (bool, error) {
+	switch format {
+	case "", "text":
+		return false, nil
+	case "json":
+		return true, nil
+	}
+	return false, fmt.Errorf("unknown format '%s'. Use 'text' or 'json'", format)
+}
+
+func printSopVersion(cfg config.Config, version string) {
+	labels := []string{}
+	if effectiveDefaultSop(cfg) == version {
+		labels = append(labels, "default")
+	}
+	for _, channel := range install.SopChannels {
+		if cfg.Channels[channel] == version {
+			labels = append(labels, channel)
+		}
+	}
+	if source := install.SopSource(version); source != "" {
+		labels = append(labels, source)
+	}
+
+	if len(labels) == 0 {
+		ui.Out.Printf("  %s\n", version)
+	} else {
+		if labels[0] == "default" {
+			ui.Out.Printf("  %s %s\n", ui.Out.Green(version), ui.Out.Dim("(" + strings.Join(labels, ", ") + ")"))
+		} else {
+			ui.Out.Printf("  %s %s\n", version, ui.Out.Dim("(" + strings.Join(labels, ", ") + ")"))
+		}
+	}
+}
+
+// pruneTarget is an unused version prune offers to remove
+type pruneTarget struct {
+	tool string
+	version string
+	dir string
+	remove func(string) error
+}
+
+// printProjectPins prints what a project pins and whether each pin is installed
+func printProjectPins(cfg config.Config, projectCfg *config.ProjectConfig, installedSop []string, installedGo []string) {
+	wantSop, wantGo, _err0 := projectCfg.Wanted(cfg)
+	if _err0 != nil {
+		err := _err0
+		ui.Out.Printf("  %s %s\n", ui.Out.Red("✗"), err)
+		return
+	}
+	if projectCfg.Toolchain != nil {
+		ui.Out.Printf("  toolchain %s\n", (*projectCfg.Toolchain))
+	}
+
+	if wantSop == "" && wantGo == "" {
+		ui.Out.Println("  " + ui.Out.Dim("no pins, uses the defaults"))
+		return
+	}
+	if wantSop != "" {
+		resolved := wantSop
+		if channelVersion, ok := cfg.Channels[wantSop]; ok {
+			resolved = channelVersion
+		}
+		printPin("sop", wantSop, shim.ResolveInstalledVersion(resolved, installedSop))
+	}
+	if wantGo != "" {
+		printPin("go", wantGo, shim.ResolveInstalledGo(wantGo, installedGo))
+	}
+}
+
+// printPin prints one pinned version and the installed version it resolves to, if any
+func printPin(tool string, wanted string, installed string) {
+	if installed == "" {
+		ui.Out.Printf("  %s %s %s %s\n", ui.Out.Red("✗"), tool, wanted, ui.Out.Dim("(not installed)"))
+	} else {
+		if installed != wanted {
+			ui.Out.Printf("  %s %s %s %s\n", ui.Out.Green("✓"), tool, wanted, ui.Out.Dim("(" + installed + ")"))
+		} else {
+			ui.Out.Printf("  %s %s %s\n", ui.Out.Green("✓"), tool, wanted)
+		}
+	}
+}
+
+// projectFileFor returns the version file to pin in: the nearest one, or a new
+// sop.mod in the current directory. name picks another file in the same directory.
+func projectFileFor(name string) (string, error) {
+	if name != "" && (!slices.Contains(config.VersionFiles, name)) {
+		return "", fmt.Errorf("unknown version file '%s'. Use %s", name, strings.Join(config.VersionFiles, ", "))
+	}
+
+	path, _err0 := shim.FindProjectFile()
+	if _err0 != nil {
+		return "", _err0
+	}
+	if path == "" {
+		dir, _err1 := os.Getwd()
+		if _err1 != nil {
+			return "", _err1
+		}
+		path = filepath.Join(dir, "sop.mod")
+	}
+	if name != "" {
+		path = filepath.Join(filepath.Dir(path), name)
+	}
+	return path, nil
+}
+
+// installPinned installs whatever the nearest project pins that isn't
+// installed yet, or, in a workspace, whatever the root and any member pins
+func installPinned(verbose bool) error {
+	files := []string{}
+	workspace, _err0 := shim.FindWorkspace()
+	if _err0 != nil {
+		return _err0
+	}
+	if workspace != nil {
+		members, _err1 := workspace.WorkspaceMembers()
+		if _err1 != nil {
+			return _err1
+		}
+		files = append([]string{workspace.Path}, members...)
+	} else {
+		path, _err2 := shim.FindProjectFile()
+		if _err2 != nil {
+			return _err2
+		}
+		if path == "" {
+			return fmt.Errorf("no version file here or in its parents. Name what to install, e.g. `sopmod install sop latest`")
+		}
+		files = append(files, path)
+	}
+
+	cfg := config.Load()
+	installedSop := install.ListInstalledSop()
 	installedGo := install.ListInstalledGo()
+	missing := []install.Spec{}
+	for _, file := range files {
+		pins, _err3 := config.LoadPins(file)
+		if _err3 != nil {
+			err := _err3
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		wantSop, wantGo, _err4 := pins.Wanted(cfg)
+		if _err4 != nil {
+			err := _err4
+			return fmt.Errorf("%s: %w", file, err)
+		}
 
-	// Find best compatible installed version
-	var best string
-	for _, v := range installedGo {
-		if compat.IsGoCompatible(v, sopVersion) {
-			if best == "" || shim.CompareVersions(v, best) > 0 {
-				best = v
+		if wantSop != "" {
+			resolved := wantSop
+			if channelVersion, ok := cfg.Channels[wantSop]; ok {
+				resolved = channelVersion
+			}
+			if shim.ResolveInstalledVersion(resolved, installedSop) == "" && (!slices.Contains(missing, install.Spec{Tool: "sop", Version: wantSop})) {
+				missing = append(missing, install.Spec{Tool: "sop", Version: wantSop})
 			}
 		}
+		if wantGo != "" && shim.ResolveInstalledGo(wantGo, installedGo) == "" && (!slices.Contains(missing, install.Spec{Tool: "go", Version: wantGo})) {
+			missing = append(missing, install.Spec{Tool: "go", Version: wantGo})
+		}
 	}
 
-	if best != "" {
-		return best, nil
+	if len(missing) == 0 {
+		ui.Success("Everything %s pins is installed", pinnedSource(files))
+		return nil
 	}
+	return installSpecs(missing, verbose)
+}
 
-	// No compatible Go installed, install latest
-	fmt.Printf("\033[36m→\033[0m Installing go (sop %s requires \033[1m%s+\033[0m)...\n", sopVersion, compatInfo.Min)
-	return install.InstallGo("latest", false)
+// recordSopInstall remembers the version a channel install resolved to and
+// makes the first sop installed the default
+func recordSopInstall(requested string, resolved string) error {
+	cfg := config.Load()
+	channel := sopChannelOf(requested)
+	if channel != "" {
+		cfg.SetChannel(channel, resolved)
+		_err0 := cfg.Save()
+		if _err0 != nil {
+			return _err0
+		}
+	}
+
+	// Set as default if no default exists
+	if cfg.DefaultSop == nil {
+		ui.Step("Setting sop %s as default (first install)", ui.Err.Bold(resolved))
+		return setDefaultSop(resolved, channel)
+	}
+	return nil
+}
+
+// pinnedSource names what installPinned read pins from
+func pinnedSource(files []string) string {
+	if len(files) == 1 {
+		return files[0]
+	}
+	return fmt.Sprintf("the workspace (%d modules)", len(files))
+}
+
+// installSpecs resolves every spec, installs each distinct version once with
+// the downloads in parallel, then records sop channels and the first default.
+// More than one spec gets a summary table.
+func installSpecs(specs []install.Spec, verbose bool) error {
+	results := install.ResolveSpecs(specs, install.ResolveSpec)
+	jobs := install.FetchJobs(results, func(spec install.Spec) error {
+		return install.InstallSpec(spec, verbose)
+	})
+	// The first sop installed becomes the default, which wants a go that suits
+	// it. Fetch that alongside sop rather than after.
+	if config.Load().DefaultSop == nil {
+		firstSop := ""
+		installingGo := []string{}
+		for _, result := range results {
+			if result.Err != nil {
+				continue
+			}
+			if result.Spec.Tool == "go" {
+				installingGo = append(installingGo, result.Resolved)
+			} else {
+				if firstSop == "" {
+					firstSop = result.Resolved
+				}
+			}
+		}
+		if firstSop != "" {
+			jobs = append(jobs, compatibleGoJob(firstSop, installingGo)...)
+		}
+	}
+	install.RunJobs(jobs, install.Workers)
+	failed := install.Settle(results)
+
+	// config.toml is only written once the downloads are done, one at a time.
+	// A write that fails is reported against its spec like a failed install.
+	for i := range results {
+		if results[i].Err == nil && results[i].Spec.Tool == "sop" {
+			results[i].Err = recordSopInstall(results[i].Spec.Version, results[i].Resolved)
+			if results[i].Err != nil {
+				failed++
+			}
+		}
+	}
+
+	if len(results) == 1 {
+		return results[0].Err
+	}
+	printInstallSummary(results)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d installs failed", failed, len(results))
+	}
+	return nil
+}
+
+// printInstallSummary prints a row per requested spec with the version it
+// resolved to and how its install went
+func printInstallSummary(results []install.Result) {
+	rows := [][]string{{"TOOL", "REQUESTED", "VERSION"}}
+	for _, result := range results {
+		resolved := result.Resolved
+		if resolved == "" {
+			resolved = "-"
+		}
+		rows = append(rows, []string{result.Spec.Tool, result.Spec.Version, resolved})
+	}
+	widths := make([]int, 3)
+	for _, row := range rows {
+		for c, cell := range row {
+			widths[c] = max(widths[c], len(cell))
+		}
+	}
+
+	ui.Out.Println()
+	for i, row := range rows {
+		line := ""
+		for c, cell := range row {
+			line += cell + strings.Repeat(" ", widths[c] - len(cell) + 2)
+		}
+		if i == 0 {
+			ui.Out.Println(ui.Out.Bold(line + "STATUS"))
+			continue
+		}
+
+		result := results[i - 1]
+		if result.Err != nil {
+			ui.Out.Println(line + ui.Out.Red("✗ " + result.Err.Error()))
+		} else {
+			ui.Out.Println(line + ui.Out.Green("✓ " + result.Status))
+		}
+	}
 }
 
 func init() {
-	runtime.RegisterAttr("main.InstallCmd", "", slap.Command{Name: "install", About: "Install a Go or sop version"})
-	runtime.RegisterAttr("main.InstallCmd", "Tool", slap.Arg{Position: 0, Help: "Tool to install (go or sop)"})
-	runtime.RegisterAttr("main.InstallCmd", "Version", slap.Arg{Position: 1, Help: "Version to install (e.g. latest, 1.23.0)"})
+	runtime.RegisterAttr("main.InstallCmd", "", slap.Command{Name: "install", About: "Install Go and sop versions"})
+	runtime.RegisterAttr("main.InstallCmd", "Specs", slap.Arg{Position: 0, Help: "Versions to install as tool@version, e.g. sop@0.5.1 go@1.23 sop@beta, or a tool and version (omit to install what the project or workspace pins)", Optional: true})
 	runtime.RegisterAttr("main.InstallCmd", "Verbose", slap.Flag{Short: "v", Long: "verbose", Help: "Verbose output"})
+	runtime.RegisterAttr("main.InstallCmd", "Git", slap.Flag{Long: "git", Help: "Build sop from a git ref of the soppo repository, installed under the given version name"})
 	runtime.RegisterAttr("main.ListCmd", "", slap.Command{Name: "list", About: "List installed versions"})
 	runtime.RegisterAttr("main.ListCmd", "Tool", slap.Arg{Position: 0, Help: "Tool to list (go or sop, omit for both)", Optional: true})
+	runtime.RegisterAttr("main.ListCmd", "Format", slap.Flag{Long: "format", Help: "Output format (text or json)"})
 	runtime.RegisterAttr("main.DefaultCmd", "", slap.Command{Name: "default", About: "Set the default sop version"})
-	runtime.RegisterAttr("main.DefaultCmd", "Version", slap.Arg{Position: 0, Help: "Version to set as default"})
+	runtime.RegisterAttr("main.DefaultCmd", "Version", slap.Arg{Position: 0, Help: "Version or channel (stable, beta, nightly) to set as default", Optional: true})
+	runtime.RegisterAttr("main.DefaultCmd", "Toolchain", slap.Flag{Short: "t", Long: "toolchain", Help: "Use a named toolchain from config.toml instead of a version"})
 	runtime.RegisterAttr("main.RemoveCmd", "", slap.Command{Name: "remove", About: "Remove an installed version"})
 	runtime.RegisterAttr("main.RemoveCmd", "Tool", slap.Arg{Position: 0, Help: "Tool to remove (go or sop)"})
 	runtime.RegisterAttr("main.RemoveCmd", "Version", slap.Arg{Position: 1, Help: "Version to remove"})
 	runtime.RegisterAttr("main.UpdateCmd", "", slap.Command{Name: "update", About: "Update to the latest version"})
 	runtime.RegisterAttr("main.UpdateCmd", "Tool", slap.Arg{Position: 0, Help: "Tool to update (go or sop, omit for both)", Optional: true})
+	runtime.RegisterAttr("main.LinkCmd", "", slap.Command{Name: "link", About: "Link a local sop build as a named toolchain"})
+	runtime.RegisterAttr("main.LinkCmd", "Tool", slap.Arg{Position: 0, Help: "Tool to link (sop)"})
+	runtime.RegisterAttr("main.LinkCmd", "Name", slap.Arg{Position: 1, Help: "Toolchain name (e.g. dev)"})
+	runtime.RegisterAttr("main.LinkCmd", "Path", slap.Arg{Position: 2, Help: "Directory containing the sop and sopls binaries"})
+	runtime.RegisterAttr("main.DoctorCmd", "", slap.Command{Name: "doctor", About: "Check the sopmod setup for problems"})
+	runtime.RegisterAttr("main.DoctorCmd", "Strict", slap.Flag{Long: "strict", Help: "Treat warnings as failures"})
+	runtime.RegisterAttr("main.DoctorCmd", "Format", slap.Flag{Long: "format", Help: "Output format (text or json)"})
+	runtime.RegisterAttr("main.PruneCmd", "", slap.Command{Name: "prune", About: "Remove versions that nothing uses"})
+	runtime.RegisterAttr("main.PruneCmd", "DryRun", slap.Flag{Long: "dry-run", Help: "Show what would be removed without removing anything"})
+	runtime.RegisterAttr("main.PruneCmd", "Keep", slap.Flag{Long: "keep", Help: "Also keep the N newest versions of each tool"})
+	runtime.RegisterAttr("main.ProjectsCmd", "", slap.Command{Name: "projects", About: "List projects using sopmod and whether their pins are installed"})
+	runtime.RegisterAttr("main.SelfCmd", "", slap.Command{Name: "self", About: "Update or uninstall sopmod itself"})
+	runtime.RegisterAttr("main.SelfCmd", "Action", slap.Arg{Position: 0, Help: "Action to run (update or uninstall)"})
+	runtime.RegisterAttr("main.SelfCmd", "Verbose", slap.Flag{Short: "v", Long: "verbose", Help: "Verbose output"})
+	runtime.RegisterAttr("main.ShimCmd", "", slap.Command{Name: "shim", About: "Manage the sop and sopls shims"})
+	runtime.RegisterAttr("main.ShimCmd", "Action", slap.Arg{Position: 0, Help: "Action to run (refresh)"})
+	runtime.RegisterAttr("main.WhichCmd", "", slap.Command{Name: "which", About: "Show which binary a tool resolves to here"})
+	runtime.RegisterAttr("main.WhichCmd", "Tool", slap.Arg{Position: 0, Help: "Tool to look up (sop, sopls or go, default sop)", Optional: true})
+	runtime.RegisterAttr("main.WhichCmd", "Format", slap.Flag{Long: "format", Help: "Output format (text or json)"})
+	runtime.RegisterAttr("main.EnvCmd", "", slap.Command{Name: "env", About: "Show the environment the shims run tools with"})
+	runtime.RegisterAttr("main.EnvCmd", "Format", slap.Flag{Long: "format", Help: "Output format (text or json)"})
+	runtime.RegisterAttr("main.CompatCmd", "", slap.Command{Name: "compat", About: "Manage the Go compatibility manifest"})
+	runtime.RegisterAttr("main.CompatCmd", "Action", slap.Arg{Position: 0, Help: "Action to run (refresh)"})
+	runtime.RegisterAttr("main.CompatCmd", "Verbose", slap.Flag{Short: "v", Long: "verbose", Help: "Verbose output"})
+	runtime.RegisterAttr("main.PinCmd", "", slap.Command{Name: "pin", About: "Pin a go or sop version for the project"})
+	runtime.RegisterAttr("main.PinCmd", "Tool", slap.Arg{Position: 0, Help: "Tool to pin (go or sop)"})
+	runtime.RegisterAttr("main.PinCmd", "Version", slap.Arg{Position: 1, Help: "Version to pin, e.g. 0.5, 1.23 or latest"})
+	runtime.RegisterAttr("main.PinCmd", "File", slap.Flag{Long: "file", Help: "Version file to write: sop.mod, .sop-version or .tool-versions (default: the project's own, else sop.mod)"})
+	runtime.RegisterAttr("main.BumpCmd", "", slap.Command{Name: "bump", About: "Move the project's pins to the latest compatible releases"})
+	runtime.RegisterAttr("main.BumpCmd", "Tool", slap.Arg{Position: 0, Help: "Tool to bump (go or sop, omit for both)", Optional: true})
+	runtime.RegisterAttr("main.LocalCmd", "", slap.Command{Name: "local", About: "Override the sop or go version in this directory, just for you"})
+	runtime.RegisterAttr("main.LocalCmd", "Version", slap.Arg{Position: 0, Help: "Version to use here, e.g. 0.6.0-rc1 (omit to show the override)", Optional: true})
+	runtime.RegisterAttr("main.LocalCmd", "Go", slap.Flag{Long: "go", Help: "Override go rather than sop"})
+	runtime.RegisterAttr("main.LocalCmd", "Unset", slap.Flag{Long: "unset", Help: "Remove this directory's override (only go's with --go)"})
 	runtime.RegisterAttr("main.Cmd", "", slap.Command{Name: "sopmod", About: "Soppo version manager", Version: version})
 	runtime.RegisterAttr("main.Cmd", "", slap.Subcommands{})
 	runtime.RegisterAttr("main.Cmd", "Install", runtime.EnumVariant{WrapperType: Cmd_Install{}})
@@ -503,4 +1774,16 @@ func init() {
 	runtime.RegisterAttr("main.Cmd", "Default", runtime.EnumVariant{WrapperType: Cmd_Default{}})
 	runtime.RegisterAttr("main.Cmd", "Remove", runtime.EnumVariant{WrapperType: Cmd_Remove{}})
 	runtime.RegisterAttr("main.Cmd", "Update", runtime.EnumVariant{WrapperType: Cmd_Update{}})
+	runtime.RegisterAttr("main.Cmd", "Link", runtime.EnumVariant{WrapperType: Cmd_Link{}})
+	runtime.RegisterAttr("main.Cmd", "Doctor", runtime.EnumVariant{WrapperType: Cmd_Doctor{}})
+	runtime.RegisterAttr("main.Cmd", "Prune", runtime.EnumVariant{WrapperType: Cmd_Prune{}})
+	runtime.RegisterAttr("main.Cmd", "Projects", runtime.EnumVariant{WrapperType: Cmd_Projects{}})
+	runtime.RegisterAttr("main.Cmd", "Self", runtime.EnumVariant{WrapperType: Cmd_Self{}})
+	runtime.RegisterAttr("main.Cmd", "Shim", runtime.EnumVariant{WrapperType: Cmd_Shim{}})
+	runtime.RegisterAttr("main.Cmd", "Which", runtime.EnumVariant{WrapperType: Cmd_Which{}})
+	runtime.RegisterAttr("main.Cmd", "Env", runtime.EnumVariant{WrapperType: Cmd_Env{}})
+	runtime.RegisterAttr("main.Cmd", "Compat", runtime.EnumVariant{WrapperType: Cmd_Compat{}})
+	runtime.RegisterAttr("main.Cmd", "Pin", runtime.EnumVariant{WrapperType: Cmd_Pin{}})
+	runtime.RegisterAttr("main.Cmd", "Bump", runtime.EnumVariant{WrapperType: Cmd_Bump{}})
+	runtime.RegisterAttr("main.Cmd", "Local", runtime.EnumVariant{WrapperType: Cmd_Local{}})
 }